@@ -0,0 +1,66 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/bench"
+	"github.com/tbeets/gonats-101/internal/cmd/contexts"
+	"github.com/tbeets/gonats-101/internal/cmd/echo"
+	"github.com/tbeets/gonats-101/internal/cmd/info"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddconsumer"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddsourcestream"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jsfetch"
+	"github.com/tbeets/gonats-101/internal/cmd/jsfetchforever"
+	"github.com/tbeets/gonats-101/internal/cmd/jspub"
+	"github.com/tbeets/gonats-101/internal/cmd/jspubasync"
+	"github.com/tbeets/gonats-101/internal/cmd/jssub"
+	"github.com/tbeets/gonats-101/internal/cmd/microhello"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/qsub"
+	"github.com/tbeets/gonats-101/internal/cmd/replay"
+	"github.com/tbeets/gonats-101/internal/cmd/reply"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/cmd/reqmulti"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+)
+
+// commands are the gonats subcommands, in the order listed by help.
+var commands = []*cli.Command{
+	pub.Command,
+	sub.Command,
+	qsub.Command,
+	req.Command,
+	reqmulti.Command,
+	reply.Command,
+	echo.Command,
+	bench.Command,
+	info.Command,
+	replay.Command,
+	contexts.Command,
+	jsaddstream.Command,
+	jsaddsourcestream.Command,
+	jsaddconsumer.Command,
+	jspub.Command,
+	jspubasync.Command,
+	jsfetch.Command,
+	jsfetchforever.Command,
+	jssub.Command,
+	microhello.Command,
+}
+
+func main() {
+	cli.Dispatch(commands)
+}
@@ -0,0 +1,254 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capture records the messages received to capture files, one JSON
+// message object per line, as documented in docs/capture-format.md, and reads
+// them back. Capture files are appended to, rotated by size or age, and
+// optionally gzipped.
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// flushInterval is how often a gzipped capture file is flushed, trading a
+// little compression for losing at most this much on a crash.
+const flushInterval = time.Second
+
+// Options are the capture flags.
+type Options struct {
+	// File is the capture file, none if empty.
+	File string
+	// MaxSize rotates the file once this many bytes are written to it, before
+	// any compression, 0 for no limit. A plain file left from before counts
+	// its size, a gzipped one is rotated when opened.
+	MaxSize int64
+	// MaxAge rotates the file once it is this old, 0 for no limit. A file
+	// left from before is rotated when opened.
+	MaxAge time.Duration
+	// Gzip compresses the file.
+	Gzip bool
+}
+
+// AddFlags registers the capture flags on fs.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "capture", "", "Append the messages received to this capture file, as JSON lines")
+	fs.Int64Var(&o.MaxSize, "capture-size", 0, "Rotate the capture file once this many bytes are written to it, 0 for no limit")
+	fs.DurationVar(&o.MaxAge, "capture-age", 0, "Rotate the capture file once this old, 0 for no limit")
+	fs.BoolVar(&o.Gzip, "capture-gzip", false, "Compress the capture file with gzip")
+}
+
+// Writer writes the messages received to the capture file. It is safe for
+// concurrent use.
+type Writer struct {
+	o Options
+
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	gz      *gzip.Writer
+	size    int64
+	opened  time.Time
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Open opens the capture file, returning nil if none.
+func (o *Options) Open() (*Writer, error) {
+	if o.File == "" {
+		if o.MaxSize != 0 || o.MaxAge != 0 || o.Gzip {
+			return nil, status.Invalidf("-capture-size, -capture-age and -capture-gzip need -capture")
+		}
+		return nil, nil
+	}
+	if o.MaxSize < 0 || o.MaxAge < 0 {
+		return nil, status.Invalidf("-capture-size and -capture-age must not be negative")
+	}
+	w := &Writer{o: *o}
+	left, err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	// Neither the age of a file left from before is known, nor the size of
+	// its messages if gzipped: rather than guess, it is rotated first.
+	if left && (o.MaxAge > 0 || o.Gzip && o.MaxSize > 0) {
+		if err := w.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	if o.Gzip {
+		w.stop, w.stopped = make(chan struct{}), make(chan struct{})
+		go w.flushEvery(flushInterval)
+	}
+	return w, nil
+}
+
+// open opens the capture file for appending, reporting whether it was left
+// from before, not empty.
+func (w *Writer) open() (bool, error) {
+	f, err := os.OpenFile(w.o.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return false, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return false, err
+	}
+	w.f, w.size, w.opened = f, fi.Size(), time.Now()
+	var out io.Writer = f
+	if w.o.Gzip {
+		// Each gzip stream appended is a member of the same gzip file. Its
+		// size on disk is compressed, so only the bytes written are counted.
+		w.gz = gzip.NewWriter(f)
+		out = w.gz
+		w.size = 0
+	}
+	w.w = bufio.NewWriter(out)
+	return fi.Size() > 0, nil
+}
+
+// Write writes m, the seq'th message received, rotating the capture file
+// first if due. A nil Writer writes nothing.
+func (w *Writer) Write(m *nats.Msg, seq int) error {
+	if w == nil {
+		return nil
+	}
+	line, err := json.Marshal(output.NewMessage(m, seq))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.size > 0 && (w.o.MaxSize > 0 && w.size+int64(len(line)) > w.o.MaxSize || w.o.MaxAge > 0 && time.Since(w.opened) >= w.o.MaxAge) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.w.Write(line); err != nil {
+		return err
+	}
+	w.size += int64(len(line))
+	if w.gz == nil {
+		return w.flush()
+	}
+	return nil
+}
+
+// flushEvery flushes a gzipped capture file every interval until stopped.
+// An error flushing is kept by the writers, returned by the next Write or
+// Close.
+func (w *Writer) flushEvery(interval time.Duration) {
+	defer close(w.stopped)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.mu.Lock()
+			w.flush()
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *Writer) flush() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Flush()
+	}
+	return nil
+}
+
+// close closes the capture file.
+func (w *Writer) close() error {
+	err := w.w.Flush()
+	if w.gz != nil {
+		if cerr := w.gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// rotate renames the capture file with the time of rotation, such as
+// capture-20230120T160405.123Z.jsonl, and opens a new one.
+func (w *Writer) rotate() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	// Files rotated within the same millisecond are told apart by the next
+	// free millisecond, keeping their names in order.
+	t := time.Now()
+	name := rotatedName(w.o.File, t)
+	for {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			break
+		}
+		t = t.Add(time.Millisecond)
+		name = rotatedName(w.o.File, t)
+	}
+	if err := os.Rename(w.o.File, name); err != nil {
+		return err
+	}
+	_, err := w.open()
+	return err
+}
+
+// rotatedName returns the name of capture file path rotated at t, the time
+// inserted before the extensions of the file name.
+func rotatedName(path string, t time.Time) string {
+	dir, base := filepath.Split(path)
+	name, ext := base, ""
+	if i := strings.Index(base, "."); i > 0 {
+		name, ext = base[:i], base[i:]
+	}
+	return filepath.Join(dir, name+"-"+t.UTC().Format("20060102T150405.000Z")+ext)
+}
+
+// Close flushes and closes the capture file. Closing a nil Writer does
+// nothing.
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	if w.stop != nil {
+		close(w.stop)
+		<-w.stopped
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.close()
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// maxLine is the longest line read from a capture file, enough for the
+// largest payload a server accepts, in base64.
+const maxLine = 128 << 20
+
+// Reader reads the messages of a capture file, gzipped or not.
+type Reader struct {
+	name    string
+	line    int
+	scanner *bufio.Scanner
+	close   func() error
+}
+
+// Open opens the capture file at path, or stdin if path is "-".
+func Open(path string, stdin io.Reader) (*Reader, error) {
+	r := &Reader{name: path, close: func() error { return nil }}
+	in := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		in, r.close = f, f.Close
+	}
+	br := bufio.NewReader(in)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			r.close()
+			return nil, status.Invalidf("%s: %v", path, err)
+		}
+		in = gz
+	} else {
+		in = br
+	}
+	r.scanner = bufio.NewScanner(in)
+	r.scanner.Buffer(nil, maxLine)
+	return r, nil
+}
+
+// Next returns the next message captured, as recorded and as the message to
+// publish again, skipping the lines of other types. It returns io.EOF at the
+// end of the file.
+func (r *Reader) Next() (*output.Message, *nats.Msg, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var m output.Message
+		if err := json.Unmarshal(line, &m); err != nil {
+			return nil, nil, status.Invalidf("%s:%d: %v", r.name, r.line, err)
+		}
+		if m.Type != "message" {
+			continue
+		}
+		data := []byte(m.Data)
+		if m.DataBase64 != nil {
+			data = m.DataBase64
+		}
+		return &m, &nats.Msg{Subject: m.Subject, Header: m.Headers, Data: data}, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, nil, status.Invalidf("%s:%d: %v", r.name, r.line+1, err)
+	}
+	return nil, nil, io.EOF
+}
+
+// Close closes the capture file.
+func (r *Reader) Close() error {
+	return r.close()
+}
@@ -0,0 +1,286 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli runs the commands, either as subcommands of the gonats binary
+// or each as its own nats-* binary, taking care of flag parsing and usage.
+// Commands can also be run in-process with Run, as the tests do.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/metrics"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+	"github.com/tbeets/gonats-101/internal/trace"
+)
+
+// ErrUsage is returned by a command invoked with bad arguments, once its
+// usage has been shown.
+var ErrUsage = status.Invalidf("bad usage")
+
+// Command is a command, run as "gonats <Name>" or as its own Binary.
+type Command struct {
+	// Name is the subcommand name, such as "pub" or "js add-stream".
+	Name string
+	// Binary is the name of the command's own binary, such as "nats-pub".
+	Binary string
+	// Usage is the synopsis of the command's own flags and arguments, one
+	// line per form of the command.
+	Usage string
+	// Short is a one line description.
+	Short string
+	// ConnName is the connection name of a command connecting to NATS, which
+	// then accepts the connection flags. It is empty for other commands.
+	ConnName string
+	// LongRunning is set for a command running until interrupted, which
+	// then shuts down cleanly on SIGINT or SIGTERM.
+	LongRunning bool
+	// Metrics is set for a long-running command handling messages, which
+	// then accepts -metrics-addr and records its Env.Metrics.
+	Metrics bool
+	// Trace is set for a command propagating W3C trace context, which then
+	// accepts the tracing flags and traces with its Env.Tracer.
+	Trace bool
+	// Flags registers the command's own flags on fs and returns the function
+	// running the command with its arguments.
+	Flags func(fs *flag.FlagSet) func(e *Env, args []string) error
+}
+
+// Env is the environment a command runs in.
+type Env struct {
+	// Conn holds the connection settings, nil for commands not connecting to NATS.
+	Conn *conn.Options
+	// Out writes -json output to Stdout.
+	Out *output.Printer
+	// Stdin is read by commands taking their input from stdin.
+	Stdin io.Reader
+	// Stdout receives the command's results.
+	Stdout io.Writer
+	// Log receives log messages, on stderr.
+	Log *log.Logger
+	// Metrics records the message handling, nil for commands without metrics.
+	Metrics *metrics.Metrics
+	// Tracer traces the messages, nil for commands without tracing.
+	Tracer *trace.Tracer
+
+	prog     string
+	cmd      *Command
+	fs       *flag.FlagSet
+	showHelp *bool
+
+	stop <-chan struct{}
+}
+
+// Usage logs the usage of the command.
+func (e *Env) Usage() {
+	prefix := "Usage:"
+	for _, line := range strings.Split(e.cmd.Usage, "\n") {
+		synopsis := []string{e.prog}
+		if e.Conn != nil {
+			synopsis = append(synopsis, conn.Usage)
+		}
+		synopsis = append(synopsis, "[-json]")
+		if e.Metrics != nil {
+			synopsis = append(synopsis, "[-metrics-addr addr]")
+		}
+		if e.Tracer != nil {
+			synopsis = append(synopsis, "[-trace-file file] [-trace-otlp url]")
+		}
+		if line != "" {
+			synopsis = append(synopsis, line)
+		}
+		e.Log.Printf("%s %s\n", prefix, strings.Join(synopsis, " "))
+		prefix = "      "
+	}
+	e.fs.PrintDefaults()
+}
+
+// UsageError logs the usage of the command and returns ErrUsage.
+func (e *Env) UsageError() error {
+	e.Usage()
+	return ErrUsage
+}
+
+// Interrupted returns a channel closed once the command is interrupted: by
+// SIGINT or SIGTERM when a long-running command is run as a binary, or as
+// requested by the caller of Run.
+func (e *Env) Interrupted() <-chan struct{} {
+	return e.stop
+}
+
+// handleSignals interrupts a long-running command run as a binary on SIGINT
+// or SIGTERM. A second signal exits at once, with status 128 plus the signal
+// number.
+func (e *Env) handleSignals() {
+	stop := make(chan struct{})
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		e.Log.Printf("Caught %v, shutting down", sig)
+		close(stop)
+		sig = <-c
+		e.Log.Printf("Caught %v again, exiting", sig)
+		if s, ok := sig.(syscall.Signal); ok {
+			os.Exit(128 + int(s))
+		}
+		os.Exit(status.Error)
+	}()
+	e.stop = stop
+}
+
+// Drain drains nc, so that the messages already received are handled and
+// the resulting replies and acks sent, and waits for it to be closed.
+func (e *Env) Drain(nc *nats.Conn) error {
+	e.Log.Printf("Draining...")
+	if err := conn.Drain(nc); err != nil {
+		return err
+	}
+	e.Log.Printf("Exiting")
+	return nil
+}
+
+// Main runs c as its own binary, with the command line arguments.
+func Main(c *Command) {
+	log.SetFlags(0)
+	exit(c.run(c.Binary, os.Args[1:], nil, os.Stdout, os.Stderr, nil))
+}
+
+// Run runs c in-process with args, writing to stdout and stderr. Closing
+// stop interrupts the command as SIGINT does its binary.
+func Run(c *Command, args []string, stdout, stderr io.Writer, stop <-chan struct{}) error {
+	if stop == nil {
+		stop = make(chan struct{})
+	}
+	return c.run(c.Binary, args, nil, stdout, stderr, stop)
+}
+
+// exit exits the process with the status of err returned by a command,
+// logging err unless it is a usage error, whose usage was logged instead.
+func exit(err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(status.OK)
+	}
+	if err != nil && !errors.Is(err, ErrUsage) {
+		log.Print(err)
+	}
+	os.Exit(status.Of(err))
+}
+
+// newEnv returns the environment for running c as prog, with all its flags
+// registered, and the function running it.
+func (c *Command) newEnv(prog string, stdout, stderr io.Writer) (*Env, func(*Env, []string) error) {
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	e := &Env{Out: output.NewPrinter(stdout), Stdin: os.Stdin, Stdout: stdout, Log: log.New(stderr, "", 0), prog: prog, cmd: c, fs: fs}
+	if c.ConnName != "" {
+		e.Conn = conn.NewOptions(c.ConnName)
+		e.Conn.Log = e.Log
+		e.Conn.AddFlags(fs)
+	}
+	e.Out.AddFlags(fs)
+	if c.Metrics {
+		e.Metrics = metrics.New()
+		e.Metrics.AddFlags(fs)
+	}
+	if c.Trace {
+		e.Tracer = trace.New(c.Binary, e.Log)
+		e.Tracer.AddFlags(fs)
+	}
+	e.showHelp = fs.Bool("h", false, "Show help message")
+	run := c.Flags(fs)
+	fs.Usage = e.Usage
+	return e, run
+}
+
+// run runs c as prog with args. Flags set on global, the gonats flags given
+// before the subcommand, apply as if given to the command.
+func (c *Command) run(prog string, args []string, global *flag.FlagSet, stdout, stderr io.Writer, stop <-chan struct{}) error {
+	e, run := c.newEnv(prog, stdout, stderr)
+	err := e.run(run, args, global, stop)
+	if err != nil && !errors.Is(err, flag.ErrHelp) && e.Out.JSON {
+		e.Out.Print(output.NewError(err))
+	}
+	return err
+}
+
+// run parses args and runs the command with them.
+func (e *Env) run(run func(*Env, []string) error, args []string, global *flag.FlagSet, stop <-chan struct{}) error {
+	c, prog := e.cmd, e.prog
+	if stop != nil {
+		e.stop = stop
+	} else if c.LongRunning {
+		e.handleSignals()
+	}
+	fs := e.fs
+
+	var err error
+	if global != nil {
+		global.Visit(func(f *flag.Flag) {
+			if err != nil {
+				return
+			}
+			if fs.Lookup(f.Name) == nil {
+				err = status.Invalidf("%s does not accept -%s", prog, f.Name)
+				return
+			}
+			err = fs.Set(f.Name, f.Value.String())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return ErrUsage
+	}
+
+	if *e.showHelp {
+		e.Usage()
+		return flag.ErrHelp
+	}
+
+	if e.Conn != nil && e.Conn.ShowConfig {
+		return e.Conn.PrintConfig()
+	}
+
+	if e.Metrics != nil && e.Metrics.Addr != "" {
+		l, err := e.Metrics.Listen()
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		e.Log.Printf("Serving metrics on [http://%s%s]", l.Addr(), metrics.Path)
+	}
+
+	if e.Tracer != nil {
+		if err := e.Tracer.Open(); err != nil {
+			return err
+		}
+		defer e.Tracer.Close()
+	}
+
+	return run(e, fs.Args())
+}
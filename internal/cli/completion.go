@@ -0,0 +1,196 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// candidate is a completion candidate, a subcommand word or a flag.
+type candidate struct {
+	word string
+	desc string
+}
+
+// completionTree is what the completion scripts are generated from: the
+// words completed after each command path, "" being the top level.
+type completionTree struct {
+	// paths lists the command paths in order, such as "", "js" and "js pub".
+	paths []string
+	// words holds the candidates completed after each path.
+	words map[string][]candidate
+}
+
+func (t *completionTree) add(path string, c candidate) {
+	if _, ok := t.words[path]; !ok {
+		t.paths = append(t.paths, path)
+	}
+	t.words[path] = append(t.words[path], c)
+}
+
+func (t *completionTree) addFlags(path string, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		t.add(path, candidate{"-" + f.Name, f.Usage})
+	})
+}
+
+func newCompletionTree(cmds []*Command) *completionTree {
+	t := &completionTree{words: map[string][]candidate{}}
+	t.add("", candidate{"help", "Show help for a command"})
+	t.add("", candidate{"completion", "Generate a shell completion script"})
+	groups := map[string]bool{}
+	for _, c := range cmds {
+		words := strings.Fields(c.Name)
+		parent := ""
+		for i, w := range words[:len(words)-1] {
+			if path := strings.Join(words[:i+1], " "); !groups[path] {
+				groups[path] = true
+				t.add(parent, candidate{w, w + " commands"})
+			}
+			parent = strings.Join(words[:i+1], " ")
+		}
+		t.add(parent, candidate{words[len(words)-1], c.Short})
+	}
+	for _, c := range t.words[""][2:] {
+		t.add("help", c)
+	}
+	for _, c := range cmds {
+		e, _ := c.newEnv(Gonats+" "+c.Name, io.Discard, io.Discard)
+		t.addFlags(c.Name, e.fs)
+	}
+	global := globalFlags()
+	global.Bool("h", false, "Show help message")
+	t.addFlags("", global)
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		t.add("completion", candidate{shell, shell + " completion script"})
+	}
+	return t
+}
+
+// transitions returns the patterns "path:word" of the words extending a
+// command path into another one.
+func (t *completionTree) transitions() []string {
+	var ts []string
+	for _, path := range t.paths {
+		if path == "" {
+			continue
+		}
+		parent, word := "", path
+		if i := strings.LastIndex(path, " "); i >= 0 {
+			parent, word = path[:i], path[i+1:]
+		}
+		ts = append(ts, fmt.Sprintf("%q", parent+":"+word))
+	}
+	return ts
+}
+
+// Completion writes the completion script for shell, one of bash, zsh or
+// fish, for the gonats binary running cmds.
+func Completion(w io.Writer, shell string, cmds []*Command) error {
+	t := newCompletionTree(cmds)
+	switch shell {
+	case "bash":
+		t.writeBash(w)
+	case "zsh":
+		t.writeZsh(w)
+	case "fish":
+		t.writeFish(w)
+	default:
+		return status.Invalidf("unsupported shell %q, use bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+func (t *completionTree) writeBash(w io.Writer) {
+	fmt.Fprintf(w, "# bash completion for %s, generated by \"%[1]s completion bash\".\n", Gonats)
+	fmt.Fprintf(w, "_%s() {\n", Gonats)
+	fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" cmd=\"\" w words\n")
+	fmt.Fprintf(w, "\tfor w in \"${COMP_WORDS[@]:1:COMP_CWORD-1}\"; do\n")
+	fmt.Fprintf(w, "\t\tcase \"$cmd:$w\" in\n")
+	fmt.Fprintf(w, "\t\t%s) cmd=\"${cmd:+$cmd }$w\" ;;\n", strings.Join(t.transitions(), "|"))
+	fmt.Fprintf(w, "\t\tesac\n")
+	fmt.Fprintf(w, "\tdone\n")
+	fmt.Fprintf(w, "\tcase \"$cmd\" in\n")
+	for _, path := range t.paths {
+		var words []string
+		for _, c := range t.words[path] {
+			words = append(words, c.word)
+		}
+		fmt.Fprintf(w, "\t%q) words=%q ;;\n", path, strings.Join(words, " "))
+	}
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -F _%s %[1]s\n", Gonats)
+}
+
+func (t *completionTree) writeZsh(w io.Writer) {
+	fmt.Fprintf(w, "#compdef %s\n", Gonats)
+	fmt.Fprintf(w, "# zsh completion for %s, generated by \"%[1]s completion zsh\".\n", Gonats)
+	fmt.Fprintf(w, "_%s() {\n", Gonats)
+	fmt.Fprintf(w, "\tlocal cmd=\"\" w\n")
+	fmt.Fprintf(w, "\tlocal -a cands\n")
+	fmt.Fprintf(w, "\tfor w in \"${(@)words[2,CURRENT-1]}\"; do\n")
+	fmt.Fprintf(w, "\t\tcase \"$cmd:$w\" in\n")
+	fmt.Fprintf(w, "\t\t(%s) cmd=\"${cmd:+$cmd }$w\" ;;\n", strings.Join(t.transitions(), "|"))
+	fmt.Fprintf(w, "\t\tesac\n")
+	fmt.Fprintf(w, "\tdone\n")
+	fmt.Fprintf(w, "\tcase \"$cmd\" in\n")
+	for _, path := range t.paths {
+		var cands []string
+		for _, c := range t.words[path] {
+			cands = append(cands, shellQuote(c.word+":"+strings.ReplaceAll(c.desc, ":", `\:`)))
+		}
+		fmt.Fprintf(w, "\t(%q) cands=(%s) ;;\n", path, strings.Join(cands, " "))
+	}
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\t_describe '%s' cands\n", Gonats)
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "compdef _%s %[1]s\n", Gonats)
+}
+
+func (t *completionTree) writeFish(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for %s, generated by \"%[1]s completion fish\".\n", Gonats)
+	fmt.Fprintf(w, "function __%s_using\n", Gonats)
+	fmt.Fprintf(w, "\tset -l cmd \"\"\n")
+	fmt.Fprintf(w, "\tfor w in (commandline -opc)[2..-1]\n")
+	fmt.Fprintf(w, "\t\tswitch \"$cmd:$w\"\n")
+	fmt.Fprintf(w, "\t\t\tcase %s\n", strings.Join(t.transitions(), " "))
+	fmt.Fprintf(w, "\t\t\t\tset cmd (string trim -- \"$cmd $w\")\n")
+	fmt.Fprintf(w, "\t\tend\n")
+	fmt.Fprintf(w, "\tend\n")
+	fmt.Fprintf(w, "\ttest \"$cmd\" = \"$argv\"\n")
+	fmt.Fprintf(w, "end\n")
+	fmt.Fprintf(w, "complete -c %s -f\n", Gonats)
+	for _, path := range t.paths {
+		cond := shellQuote(strings.TrimSpace("__" + Gonats + "_using " + path))
+		for _, c := range t.words[path] {
+			if strings.HasPrefix(c.word, "-") {
+				fmt.Fprintf(w, "complete -c %s -n %s -o %s -d %s\n", Gonats, cond, c.word[1:], shellQuote(c.desc))
+			} else {
+				fmt.Fprintf(w, "complete -c %s -n %s -a %s -d %s\n", Gonats, cond, c.word, shellQuote(c.desc))
+			}
+		}
+	}
+}
+
+// shellQuote quotes s in single quotes, as understood by every shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Gonats is the name of the binary running every command as a subcommand.
+const Gonats = "gonats"
+
+// Dispatch runs the subcommand of cmds named by the command line arguments.
+// Global flags given before the subcommand apply to it.
+func Dispatch(cmds []*Command) {
+	log.SetFlags(0)
+
+	global := globalFlags()
+	var showHelp = global.Bool("h", false, "Show help message")
+	global.Usage = func() { usage(global, cmds) }
+	if err := global.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(status.OK)
+		}
+		os.Exit(status.Invalid)
+	}
+
+	args := global.Args()
+	if *showHelp {
+		usageAndExit(global, cmds, status.OK)
+	}
+	if len(args) == 0 {
+		usageAndExit(global, cmds, status.Invalid)
+	}
+
+	switch args[0] {
+	case "help":
+		help(global, cmds, args[1:])
+		return
+	case "completion":
+		if len(args) != 2 {
+			log.Printf("Usage: %s completion bash|zsh|fish", Gonats)
+			os.Exit(status.Invalid)
+		}
+		exit(Completion(os.Stdout, args[1], cmds))
+	}
+
+	c, n := lookup(cmds, args)
+	if c == nil {
+		group := groupCommands(cmds, args)
+		if len(group) == 0 {
+			log.Printf("%s: unknown command %q", Gonats, strings.Join(args, " "))
+			usageAndExit(global, cmds, status.Invalid)
+		}
+		listCommands(group)
+		os.Exit(status.Invalid)
+	}
+	exit(c.run(Gonats+" "+c.Name, args[n:], global, os.Stdout, os.Stderr, nil))
+}
+
+// globalFlags returns the flags accepted before the subcommand.
+func globalFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet(Gonats, flag.ContinueOnError)
+	conn.NewOptions("").AddFlags(fs)
+	output.NewPrinter(os.Stdout).AddFlags(fs)
+	return fs
+}
+
+// lookup returns the command named by the leading words of args, and the
+// number of words naming it.
+func lookup(cmds []*Command, args []string) (*Command, int) {
+	var found *Command
+	n := 0
+	for _, c := range cmds {
+		words := strings.Fields(c.Name)
+		if len(words) > len(args) || len(words) <= n {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == c.Name {
+			found, n = c, len(words)
+		}
+	}
+	return found, n
+}
+
+// groupCommands returns the commands in the group named by args, such as
+// the "js" commands.
+func groupCommands(cmds []*Command, args []string) []*Command {
+	var group []*Command
+	prefix := strings.Join(args, " ") + " "
+	for _, c := range cmds {
+		if strings.HasPrefix(c.Name, prefix) {
+			group = append(group, c)
+		}
+	}
+	return group
+}
+
+func listCommands(cmds []*Command) {
+	log.Printf("Commands:")
+	for _, c := range cmds {
+		log.Printf("  %-24s %s", c.Name, c.Short)
+	}
+}
+
+func usage(global *flag.FlagSet, cmds []*Command) {
+	log.Printf("Usage: %s [global flags] <command> [flags] [args]\n", Gonats)
+	log.Printf("       %s help <command>\n", Gonats)
+	log.Printf("       %s completion bash|zsh|fish\n\n", Gonats)
+	listCommands(cmds)
+	log.Printf("\nGlobal flags, also accepted after the command:")
+	global.PrintDefaults()
+}
+
+func usageAndExit(global *flag.FlagSet, cmds []*Command, exitcode int) {
+	usage(global, cmds)
+	os.Exit(exitcode)
+}
+
+// help shows the usage of the command named by args.
+func help(global *flag.FlagSet, cmds []*Command, args []string) {
+	if len(args) == 0 {
+		usageAndExit(global, cmds, status.OK)
+	}
+	c, n := lookup(cmds, args)
+	if c == nil || n != len(args) {
+		group := groupCommands(cmds, args)
+		if len(group) == 0 {
+			log.Printf("%s: unknown command %q", Gonats, strings.Join(args, " "))
+			os.Exit(status.Invalid)
+		}
+		listCommands(group)
+		return
+	}
+	exit(c.run(Gonats+" "+c.Name, []string{"-h"}, nil, os.Stdout, os.Stderr, nil))
+}
@@ -0,0 +1,175 @@
+// Copyright 2015-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bench benchmarks publishing and subscribing.
+package bench
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/bench"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Some sane defaults
+const (
+	DefaultNumMsgs     = 100000
+	DefaultNumPubs     = 1
+	DefaultNumSubs     = 0
+	DefaultMessageSize = 128
+)
+
+// Command is nats-bench, also run as "gonats bench".
+var Command = &cli.Command{
+	Name:     "bench",
+	Binary:   "nats-bench",
+	Usage:    "[-np NUM_PUBLISHERS] [-ns NUM_SUBSCRIBERS] [-n NUM_MSGS] [-ms MESSAGE_SIZE] [-csv csvfile] <subject>",
+	Short:    "Benchmark publishers and subscribers",
+	ConnName: "NATS Benchmark",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var o options
+		fs.IntVar(&o.numPubs, "np", DefaultNumPubs, "Number of Concurrent Publishers")
+		fs.IntVar(&o.numSubs, "ns", DefaultNumSubs, "Number of Concurrent Subscribers")
+		fs.IntVar(&o.numMsgs, "n", DefaultNumMsgs, "Number of Messages to Publish")
+		fs.IntVar(&o.msgSize, "ms", DefaultMessageSize, "Size of the message.")
+		fs.StringVar(&o.csvFile, "csv", "", "Save bench data to csv file")
+		return func(e *cli.Env, args []string) error {
+			return run(e, args, &o)
+		}
+	},
+}
+
+type options struct {
+	numPubs int
+	numSubs int
+	numMsgs int
+	msgSize int
+	csvFile string
+}
+
+func run(e *cli.Env, args []string, o *options) error {
+	if len(args) != 1 {
+		return e.UsageError()
+	}
+
+	if o.numMsgs <= 0 {
+		return status.Invalidf("Number of messages should be greater than zero.")
+	}
+
+	subj := args[0]
+	benchmark := bench.NewBenchmark("NATS", o.numSubs, o.numPubs)
+
+	var startwg sync.WaitGroup
+	var donewg sync.WaitGroup
+
+	donewg.Add(o.numPubs + o.numSubs)
+
+	// Run Subscribers first
+	startwg.Add(o.numSubs)
+	for i := 0; i < o.numSubs; i++ {
+		nc, err := e.Conn.Connect()
+		if err != nil {
+			return fmt.Errorf("Can't connect: %w", err)
+		}
+		defer nc.Close()
+
+		go runSubscriber(benchmark, nc, &startwg, &donewg, subj, o.numMsgs, o.msgSize)
+	}
+	startwg.Wait()
+
+	// Now Publishers
+	startwg.Add(o.numPubs)
+	pubCounts := bench.MsgsPerClient(o.numMsgs, o.numPubs)
+	for i := 0; i < o.numPubs; i++ {
+		nc, err := e.Conn.Connect()
+		if err != nil {
+			return fmt.Errorf("Can't connect: %w", err)
+		}
+		defer nc.Close()
+
+		go runPublisher(benchmark, nc, &startwg, &donewg, subj, pubCounts[i], o.msgSize)
+	}
+
+	e.Log.Printf("Starting benchmark [msgs=%d, msgsize=%d, pubs=%d, subs=%d]\n", o.numMsgs, o.msgSize, o.numPubs, o.numSubs)
+
+	startwg.Wait()
+	donewg.Wait()
+
+	benchmark.Close()
+
+	if e.Out.JSON {
+		e.Out.Print(output.NewBench(benchmark, o.msgSize))
+	} else {
+		fmt.Fprint(e.Stdout, benchmark.Report())
+	}
+
+	if len(o.csvFile) > 0 {
+		csv := benchmark.CSV()
+		if err := ioutil.WriteFile(o.csvFile, []byte(csv), 0644); err != nil {
+			return err
+		}
+		if !e.Out.JSON {
+			fmt.Fprintf(e.Stdout, "Saved metric data in csv file %s\n", o.csvFile)
+		}
+	}
+	return nil
+}
+
+func runPublisher(benchmark *bench.Benchmark, nc *nats.Conn, startwg, donewg *sync.WaitGroup, subj string, numMsgs int, msgSize int) {
+	startwg.Done()
+
+	var msg []byte
+	if msgSize > 0 {
+		msg = make([]byte, msgSize)
+	}
+
+	start := time.Now()
+
+	for i := 0; i < numMsgs; i++ {
+		nc.Publish(subj, msg)
+	}
+	nc.Flush()
+	benchmark.AddPubSample(bench.NewSample(numMsgs, msgSize, start, time.Now(), nc))
+
+	donewg.Done()
+}
+
+func runSubscriber(benchmark *bench.Benchmark, nc *nats.Conn, startwg, donewg *sync.WaitGroup, subj string, numMsgs int, msgSize int) {
+	received := 0
+	ch := make(chan time.Time, 2)
+	sub, _ := nc.Subscribe(subj, func(msg *nats.Msg) {
+		received++
+		if received == 1 {
+			ch <- time.Now()
+		}
+		if received >= numMsgs {
+			ch <- time.Now()
+		}
+	})
+	sub.SetPendingLimits(-1, -1)
+	nc.Flush()
+	startwg.Done()
+
+	start := <-ch
+	end := <-ch
+	benchmark.AddSubSample(bench.NewSample(numMsgs, msgSize, start, end, nc))
+	nc.Close()
+	donewg.Done()
+}
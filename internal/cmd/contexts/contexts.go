@@ -0,0 +1,232 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package contexts manages the stored connection contexts.
+package contexts
+
+import (
+	"encoding/json"
+	"flag"
+
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/natscontext"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// NOTE: Contexts are used by every other command via -context <name>, or
+// implicitly once selected.
+// nats-context add -s demo.nats.io demo
+// nats-context select demo
+
+// Command is nats-context, also run as "gonats context".
+var Command = &cli.Command{
+	Name:   "context",
+	Binary: "nats-context",
+	Usage: `add [-description text] [-s server] [-creds file] [-nkey file] [-jwt jwt -seed seed] [-user user [-password password]] [-token token] [-tlscert file] [-tlskey file] [-tlscacert file] [-domain jsdomain] [-select] <name>
+ls
+show [name]
+select [name]
+rm <name>`,
+	Short: "Manage the stored connection contexts",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		return contexts
+	},
+}
+
+func contexts(e *cli.Env, args []string) error {
+	if len(args) < 1 {
+		return e.UsageError()
+	}
+
+	switch cmd, args := args[0], args[1:]; cmd {
+	case "add":
+		return addContext(e, args)
+	case "ls":
+		return listContexts(e)
+	case "show":
+		if len(args) > 1 {
+			return e.UsageError()
+		}
+		name, err := optionalName(args)
+		if err != nil {
+			return err
+		}
+		return showContext(e, name)
+	case "select":
+		if len(args) > 1 {
+			return e.UsageError()
+		}
+		return selectContext(e, args)
+	case "rm":
+		if len(args) != 1 {
+			return e.UsageError()
+		}
+		if err := natscontext.Delete(args[0]); err != nil {
+			return err
+		}
+		e.Log.Printf("Removed context [%s]", args[0])
+	default:
+		return e.UsageError()
+	}
+	return nil
+}
+
+func addContext(e *cli.Env, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	fs.SetOutput(e.Log.Writer())
+	fs.Usage = e.Usage
+	var c natscontext.Context
+	fs.StringVar(&c.Description, "description", "", "Context description")
+	fs.StringVar(&c.URL, "s", "", "The nats server URLs (separated by comma)")
+	fs.StringVar(&c.Creds, "creds", "", "User Credentials File")
+	fs.StringVar(&c.Nkey, "nkey", "", "NKey Seed File")
+	fs.StringVar(&c.JWT, "jwt", "", "User JWT, used with -seed")
+	fs.StringVar(&c.Seed, "seed", "", "User NKey seed, used with -jwt")
+	fs.StringVar(&c.User, "user", "", "Username")
+	fs.StringVar(&c.Password, "password", "", "Password")
+	fs.StringVar(&c.Token, "token", "", "Authentication token")
+	fs.StringVar(&c.TLSCert, "tlscert", "", "TLS client certificate file")
+	fs.StringVar(&c.TLSKey, "tlskey", "", "Private key file for client certificate")
+	fs.StringVar(&c.TLSCA, "tlscacert", "", "CA certificate to verify peer against")
+	fs.StringVar(&c.JSDomain, "domain", "", "JetStream domain")
+	var sel = fs.Bool("select", false, "Select the context as the default")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return cli.ErrUsage
+	}
+
+	if fs.NArg() != 1 {
+		return e.UsageError()
+	}
+	auth := conn.Options{URLs: c.URL, UserCreds: c.Creds, NkeyFile: c.Nkey, JWT: c.JWT, Seed: c.Seed,
+		User: c.User, Password: c.Password, Token: c.Token}
+	if err := auth.CheckAuth(); err != nil {
+		return err
+	}
+
+	c.Name = fs.Arg(0)
+	if err := c.Save(); err != nil {
+		return err
+	}
+	e.Log.Printf("Saved context [%s]", c.Name)
+
+	if *sel {
+		return selectContext(e, []string{c.Name})
+	}
+	return nil
+}
+
+func listContexts(e *cli.Env) error {
+	names, err := natscontext.List()
+	if err != nil {
+		return err
+	}
+	sel, err := natscontext.Selected()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 && !e.Out.JSON {
+		e.Log.Printf("no contexts")
+		return nil
+	}
+	for _, name := range names {
+		marker := " "
+		if name == sel {
+			marker = "*"
+		}
+		c, err := natscontext.Load(name)
+		if err != nil {
+			return err
+		}
+		if e.Out.JSON {
+			e.Out.Print(output.NewContext(redact(c), name == sel))
+			continue
+		}
+		e.Log.Printf("%s %s\t%s", marker, name, c.Description)
+	}
+	return nil
+}
+
+func showContext(e *cli.Env, name string) error {
+	c, err := natscontext.Load(name)
+	if err != nil {
+		return err
+	}
+	c = redact(c)
+
+	if e.Out.JSON {
+		sel, err := natscontext.Selected()
+		if err != nil {
+			return err
+		}
+		e.Out.Print(output.NewContext(c, name == sel))
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	e.Log.Printf("Context [%s]:\n%s", c.Name, data)
+	return nil
+}
+
+// redact returns c with its secrets redacted, as they are never shown.
+func redact(c *natscontext.Context) *natscontext.Context {
+	c.URL = conn.RedactURLs(c.URL)
+	for _, secret := range []*string{&c.JWT, &c.Seed, &c.Password, &c.Token} {
+		if *secret != "" {
+			*secret = "[REDACTED]"
+		}
+	}
+	return c
+}
+
+func selectContext(e *cli.Env, args []string) error {
+	if len(args) == 0 {
+		sel, err := natscontext.Selected()
+		if err != nil {
+			return err
+		}
+		if sel == "" {
+			e.Log.Printf("no context selected")
+		} else {
+			e.Log.Printf("Selected context [%s]", sel)
+		}
+		return nil
+	}
+	if err := natscontext.Select(args[0]); err != nil {
+		return err
+	}
+	e.Log.Printf("Selected context [%s]", args[0])
+	return nil
+}
+
+// optionalName returns the named context, defaulting to the selected one.
+func optionalName(args []string) (string, error) {
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	sel, err := natscontext.Selected()
+	if err != nil {
+		return "", err
+	}
+	if sel == "" {
+		return "", status.Invalidf("no context selected")
+	}
+	return sel, nil
+}
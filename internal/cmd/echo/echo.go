@@ -0,0 +1,155 @@
+// Copyright 2018-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package echo is a service echoing every request back to its sender.
+package echo
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/trace"
+)
+
+// NOTE: Can test with demo servers.
+// nats-echo -s demo.nats.io <subject>
+// nats-echo -s demo.nats.io:4443 <subject> (TLS version)
+
+// Command is nats-echo, also run as "gonats echo".
+var Command = &cli.Command{
+	Name:        "echo",
+	Binary:      "nats-echo",
+	Usage:       "[-t] [-geo] <subject>",
+	Short:       "Run a service echoing requests back",
+	ConnName:    "NATS Echo Service",
+	LongRunning: true,
+	Metrics:     true,
+	Trace:       true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var showTime = fs.Bool("t", false, "Display timestamps")
+		var geoloc = fs.Bool("geo", false, "Display geo location of echo service")
+		return func(e *cli.Env, args []string) error {
+			return echo(e, args, *showTime, *geoloc)
+		}
+	},
+}
+
+func printMsg(e *cli.Env, m *nats.Msg, i int) {
+	if e.Out.JSON {
+		e.Out.Print(output.NewMessage(m, i))
+		return
+	}
+	e.Log.Printf("[#%d] Echoing to [%s]: %q", i, m.Reply, m.Data)
+}
+
+func echo(e *cli.Env, args []string, showTime, geoloc bool) error {
+	var geo string
+
+	if len(args) != 1 {
+		return e.UsageError()
+	}
+
+	// Lookup geo if requested
+	if geoloc {
+		var err error
+		if geo, err = lookupGeo(); err != nil {
+			return err
+		}
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	subj, i := args[0], 0
+
+	sub, err := nc.QueueSubscribe(subj, "echo", func(msg *nats.Msg) {
+		start := time.Now()
+		e.Metrics.Received(msg)
+		i++
+		if msg.Reply != "" {
+			span := e.Tracer.Continue("echo", trace.KindServer, msg)
+			printMsg(e, msg, i)
+			// Just echo back what they sent us.
+			resp := &nats.Msg{Subject: msg.Reply, Data: msg.Data}
+			if geo != "" {
+				resp.Data = []byte(fmt.Sprintf("[%s]: %q", geo, msg.Data))
+			}
+			span.Inject(resp)
+			err := nc.PublishMsg(resp)
+			if err == nil {
+				e.Metrics.Sent(resp.Data)
+			}
+			span.Finish(err)
+		}
+		e.Metrics.Handled(start)
+	})
+	if err != nil {
+		return err
+	}
+	e.Metrics.WatchConn(nc)
+	e.Metrics.WatchSub(sub)
+	nc.Flush()
+
+	if err := nc.LastError(); err != nil {
+		return err
+	}
+
+	if e.Out.JSON {
+		e.Out.Print(output.NewSubscribed(subj, "echo"))
+	} else {
+		e.Log.Printf("Echo Service listening on [%s]\n", subj)
+	}
+
+	if showTime {
+		e.Log.SetFlags(log.LstdFlags)
+	}
+
+	// Wait for the interrupt, then exit once drained.
+	<-e.Interrupted()
+	return e.Drain(nc)
+}
+
+// We only want region, country
+type geo struct {
+	// There are others..
+	Region  string
+	Country string
+}
+
+// lookup our current region and country..
+func lookupGeo() (string, error) {
+	c := &http.Client{Timeout: 2 * time.Second}
+	resp, err := c.Get("https://ipapi.co/json")
+	if err != nil || resp == nil {
+		return "", fmt.Errorf("Could not retrive geo location data: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	g := geo{}
+	if err := json.Unmarshal(body, &g); err != nil {
+		return "", fmt.Errorf("Error unmarshalling geo: %v", err)
+	}
+	return g.Region + ", " + g.Country, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package info reports the server a connection is connected to, for
+// diagnosing connection settings.
+package info
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Command is nats-info, also run as "gonats info".
+var Command = &cli.Command{
+	Name:     "info",
+	Binary:   "nats-info",
+	Usage:    "[-rtt-samples n]",
+	Short:    "Show the server connected to and its capabilities",
+	ConnName: "NATS Info",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		samples := fs.Int("rtt-samples", 5, "Number of RTT samples measured")
+		return func(e *cli.Env, args []string) error {
+			return info(e, args, *samples)
+		}
+	},
+}
+
+func info(e *cli.Env, args []string, samples int) error {
+	if len(args) != 0 {
+		return e.UsageError()
+	}
+	if samples < 1 {
+		return status.Invalidf("-rtt-samples must be at least 1")
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	rtts := make([]time.Duration, samples)
+	for i := range rtts {
+		if rtts[i], err = nc.RTT(); err != nil {
+			return err
+		}
+	}
+
+	// JetStream being unavailable is reported, not failed on: the rest of the
+	// report is what tells why.
+	ai, err := accountInfo(e, nc)
+	in := output.NewInfo(nc, rtts, ai)
+	if err != nil {
+		in.JetStream.Error = err.Error()
+	}
+	if e.Out.JSON {
+		e.Out.Print(in)
+		return nil
+	}
+
+	e.Log.Printf("Server ID:          %s", in.ServerID)
+	e.Log.Printf("Server name:        %s", in.ServerName)
+	e.Log.Printf("Version:            %s", in.Version)
+	e.Log.Printf("Cluster:            %s", orNone(in.Cluster))
+	e.Log.Printf("Connected URL:      %s", in.URL)
+	e.Log.Printf("Discovered servers: %s", orNone(strings.Join(in.DiscoveredServers, ", ")))
+	e.Log.Printf("Max payload:        %d bytes", in.MaxPayload)
+	e.Log.Printf("Headers:            %v", in.Headers)
+	if in.TLS == nil {
+		e.Log.Printf("TLS:                none")
+	} else {
+		e.Log.Printf("TLS:                %s, %s", in.TLS.Version, in.TLS.CipherSuite)
+		for i, c := range in.TLS.PeerCertificates {
+			e.Log.Printf("  [%d] Subject:      %s", i, c.Subject)
+			e.Log.Printf("      Issuer:       %s", c.Issuer)
+			e.Log.Printf("      Valid:        %s to %s", c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339))
+			if len(c.DNSNames) > 0 {
+				e.Log.Printf("      DNS names:    %s", strings.Join(c.DNSNames, ", "))
+			}
+		}
+	}
+	e.Log.Printf("RTT:                min %.3fms, avg %.3fms, max %.3fms over %d samples",
+		in.RTT.MinMS, in.RTT.AvgMS, in.RTT.MaxMS, in.RTT.Samples)
+	switch {
+	case in.JetStream.Error != "":
+		e.Log.Printf("JetStream:          unavailable: %s", in.JetStream.Error)
+	case !in.JetStream.Enabled:
+		e.Log.Printf("JetStream:          not enabled")
+	case in.JetStream.Domain != "":
+		e.Log.Printf("JetStream:          enabled, domain %s", in.JetStream.Domain)
+	default:
+		e.Log.Printf("JetStream:          enabled")
+	}
+	return nil
+}
+
+// accountInfo returns the JetStream account info, nil if JetStream is not
+// enabled.
+func accountInfo(e *cli.Env, nc *nats.Conn) (*nats.AccountInfo, error) {
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return nil, err
+	}
+	ai, err := js.AccountInfo()
+	if errors.Is(err, nats.ErrJetStreamNotEnabled) || errors.Is(err, nats.ErrJetStreamNotEnabledForAccount) {
+		return nil, nil
+	} else if err != nil {
+		return nil, conn.APIError(err)
+	}
+	return ai, nil
+}
+
+// orNone returns s, or "none" if s is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsaddconsumer creates a durable JetStream pull consumer.
+package jsaddconsumer
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/output"
+)
+
+// Command is nats-js-addconsumer, also run as "gonats js add-consumer".
+var Command = &cli.Command{
+	Name:     "js add-consumer",
+	Binary:   "nats-js-addconsumer",
+	Usage:    "<streamname> <consumername> <subfilter>",
+	Short:    "Create a durable pull consumer",
+	ConnName: "NATS JetStream Sample Publisher",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		return addConsumer
+	},
+}
+
+func addConsumer(e *cli.Env, args []string) error {
+	if len(args) != 3 {
+		return e.UsageError()
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// Create JetStream Context from NATS connection
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return err
+	}
+
+	str, con, subFilter := args[0], args[1], args[2]
+
+	// Create a JS Consumer
+	//
+	// Durable consumers have Durable name... omitting name means Ephemeral
+	// Pull consumers have dynamic DeliverSubject (DDS) so OMIT static DeliverSubject (SDS) in configuration
+	//
+	// Consumers inherit the persistence store of their associated stream (i.e. whether delivery state recorded in
+	// memory or filestore).
+	//
+	conInfo, err := js.AddConsumer(str, &nats.ConsumerConfig{
+		Durable:       con,
+		FilterSubject: subFilter,
+		AckPolicy:     nats.AckExplicitPolicy,
+	})
+
+	if err != nil {
+		return conn.APIError(err)
+	}
+
+	/* JS Consumer configuration options
+	{
+	  "durable_name": "thebarone",
+	  "deliver_policy": "all",
+	  "ack_policy": "explicit",
+	  "ack_wait": 30000000000,
+	  "max_deliver": -1,
+	  "filter_subject": "foo8.bar",
+	  "replay_policy": "instant",
+	  "max_waiting": 512,
+	  "max_ack_pending": 20000
+	}
+	*/
+
+	// Pretty print our happy result to show all defaults etc.
+	if conInfo != nil && e.Out.JSON {
+		e.Out.Print(output.NewConsumer(conInfo))
+	} else if conInfo != nil {
+		conCfg := conInfo.Config
+		jsonCfg, err := json.MarshalIndent(&conCfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		e.Log.Printf("%s", string(jsonCfg))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsaddsourcestream creates a JetStream stream sourcing from another stream.
+package jsaddsourcestream
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/output"
+)
+
+// Command is nats-js-addsourcestream, also run as "gonats js add-source-stream".
+var Command = &cli.Command{
+	Name:     "js add-source-stream",
+	Binary:   "nats-js-addsourcestream",
+	Usage:    "<stream> <source> <subfilter>",
+	Short:    "Create a stream sourcing from another stream",
+	ConnName: "NATS JetStream Sample Add Sourced Stream",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		return addSourceStream
+	},
+}
+
+func addSourceStream(e *cli.Env, args []string) error {
+	if len(args) != 3 {
+		return e.UsageError()
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// Create JetStream Context from NATS connection
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return err
+	}
+
+	str, src, subFilter := args[0], args[1], args[2]
+
+	// Create a Stream Source
+	strSrc1 := nats.StreamSource{
+		Name:          src,
+		FilterSubject: subFilter,
+	}
+
+	// Create a Stream
+	strInfo, err := js.AddStream(&nats.StreamConfig{
+		Name:    str,
+		Sources: []*nats.StreamSource{&strSrc1},
+	})
+
+	if err != nil {
+		return conn.APIError(err)
+	}
+
+	/* Stream configuration options
+	{
+	  "name": "sourcer8",
+	  "retention": "limits",
+	  "max_consumers": -1,
+	  "max_msgs": -1,
+	  "max_bytes": -1,
+	  "discard": "old",
+	  "max_age": 0,
+	  "max_msgs_per_subject": -1,
+	  "max_msg_size": -1,
+	  "storage": "file",
+	  "num_replicas": 1,
+	  "duplicate_window": 120000000000,
+	  "sources": [
+	    {
+	      "name": "interest8",
+	      "filter_subject": "foo8.bar"
+	    }
+	  ]
+	}
+	*/
+
+	// Pretty print our happy result to show all defaults etc.
+	if strInfo != nil && e.Out.JSON {
+		e.Out.Print(output.NewStream(strInfo))
+	} else if strInfo != nil {
+		strCfg := strInfo.Config
+		jsonCfg, err := json.MarshalIndent(&strCfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		e.Log.Printf("%s", string(jsonCfg))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsaddstream creates a JetStream stream.
+package jsaddstream
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/output"
+)
+
+// Command is nats-js-addstream, also run as "gonats js add-stream".
+var Command = &cli.Command{
+	Name:     "js add-stream",
+	Binary:   "nats-js-addstream",
+	Usage:    "<streamname> <subfilter>",
+	Short:    "Create a stream",
+	ConnName: "NATS JetStream Sample Publisher",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		return addStream
+	},
+}
+
+func addStream(e *cli.Env, args []string) error {
+	if len(args) != 2 {
+		return e.UsageError()
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// Create JetStream Context from NATS connection
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return err
+	}
+
+	str, subFilter := args[0], args[1]
+
+	// Create a Stream
+	strInfo, err := js.AddStream(&nats.StreamConfig{
+		Name:     str,
+		Subjects: []string{subFilter},
+	})
+	if err != nil {
+		return conn.APIError(err)
+	}
+
+	/* Stream configuration options (required)
+	{
+	  "name": "interest11",
+	  "subjects": [
+	    "foo11.*"
+	  ],
+	  "retention": "limits",
+	  "max_consumers": -1,
+	  "max_msgs": -1,
+	  "max_bytes": -1,
+	  "discard": "old",
+	  "max_age": 0,
+	  "max_msgs_per_subject": -1,
+	  "max_msg_size": -1,
+	  "storage": "file",
+	  "num_replicas": 1,
+	  "duplicate_window": 120000000000
+	}
+	*/
+
+	// Pretty print our happy result to show all defaults etc.
+	if strInfo != nil && e.Out.JSON {
+		e.Out.Print(output.NewStream(strInfo))
+	} else if strInfo != nil {
+		strCfg := strInfo.Config
+		jsonCfg, err := json.MarshalIndent(&strCfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		e.Log.Printf("%s", string(jsonCfg))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsfetch fetches a batch of messages from a JetStream pull consumer.
+package jsfetch
+
+import (
+	"flag"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/output"
+)
+
+// Command is nats-js-subdds, also run as "gonats js fetch".
+var Command = &cli.Command{
+	Name:     "js fetch",
+	Binary:   "nats-js-subdds",
+	Usage:    "[-bs batchsize] <stream> <consumer>",
+	Short:    "Fetch a batch of messages from a pull consumer",
+	ConnName: "NATS Sample JS Subscriber",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var batchSize = fs.Int("bs", 1, "fetch batch size (default 1)")
+		return func(e *cli.Env, args []string) error {
+			return fetch(e, args, *batchSize)
+		}
+	},
+}
+
+func printMsg(e *cli.Env, m *nats.Msg, i int) error {
+	if e.Out.JSON {
+		e.Out.Print(output.NewMessage(m, i))
+		return nil
+	}
+	md, err := m.Metadata()
+	if err != nil {
+		return err
+	}
+	e.Log.Printf("[%d]: %s\nSeqPair: [%v] Pending: [%d]", i, m.Subject, md.Sequence, md.NumPending)
+	return nil
+}
+
+func fetch(e *cli.Env, args []string, batchSize int) error {
+	if len(args) != 2 {
+		return e.UsageError()
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// Create JetStream Context from NATS connection
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return err
+	}
+
+	str, con := args[0], args[1]
+
+	// Simple Pull Consumer
+	// Prefer the Bind subscription option to explicitly identify the JetStream and JS Consumer
+	// and fail if not found.
+	// Note: Redundantly, the durable parameter must be passed (JS Consumer name) even with Bind opt,
+	// and the subject must match the consumer's filter subject, so we look that up.
+	ci, err := js.ConsumerInfo(str, con)
+	if err != nil {
+		return conn.APIError(err)
+	}
+	sub, err := js.PullSubscribe(ci.Config.FilterSubject, con, nats.Bind(str, con), nats.ManualAck())
+	if err != nil {
+		return err
+	}
+
+	msgs, err := sub.Fetch(
+		batchSize,
+		nats.MaxWait(5*time.Second))
+	if err != nil && err != nats.ErrTimeout {
+		return err
+	}
+
+	var atLeastOne bool
+	for i, msg := range msgs {
+		if err := printMsg(e, msg, i); err != nil {
+			return err
+		}
+		err = msg.Ack()
+		if err != nil {
+			return err
+		}
+		atLeastOne = true
+	}
+
+	if err := nc.LastError(); err != nil {
+		return err
+	}
+
+	if !atLeastOne {
+		e.Log.Printf("no messages")
+	}
+	return nil
+}
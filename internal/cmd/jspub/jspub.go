@@ -0,0 +1,110 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jspub publishes a message to a JetStream stream.
+package jspub
+
+import (
+	"errors"
+	"flag"
+	"io"
+
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/payload"
+	"github.com/tbeets/gonats-101/internal/tmpl"
+)
+
+// Command is nats-js-pub, also run as "gonats js pub".
+var Command = &cli.Command{
+	Name:     "js pub",
+	Binary:   "nats-js-pub",
+	Usage:    "[-H key:value]... [-headers-file file] [-encoding base64|hex] [-lines] <subject> <msg|@file|->",
+	Short:    "Publish a message to a stream",
+	ConnName: "NATS JetStream Sample Publisher",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var headers tmpl.Headers
+		var opts payload.Options
+		headers.AddFlags(fs)
+		opts.AddFlags(fs)
+		return func(e *cli.Env, args []string) error {
+			return pub(e, args, &headers, &opts)
+		}
+	},
+}
+
+func pub(e *cli.Env, args []string, headers *tmpl.Headers, opts *payload.Options) error {
+	if len(args) != 2 {
+		return e.UsageError()
+	}
+	hdr, err := headers.Header()
+	if err != nil {
+		return err
+	}
+	mt, err := tmpl.ParseMsg(args[0], hdr)
+	if err != nil {
+		return err
+	}
+	src, err := opts.Open(args[1], e.Stdin)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// Create JetStream Context from NATS connection
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return err
+	}
+
+	// In line mode, each line is a message.
+	for n := 1; ; n++ {
+		data, err := src.Next(n)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := payload.Check(nc, data); err != nil {
+			return err
+		}
+		m, err := mt.Render(n, data)
+		if err != nil {
+			return err
+		}
+
+		// Synchronous publish (from client's perspective) - a publish acknowledgement indicates success
+		// Since JetStreams cannot overlap subject filter namespace, subject is sufficient to publish
+		// into a stream.
+		pa, err := js.PublishMsg(m)
+		if err != nil {
+			return err
+		}
+
+		if pa != nil && e.Out.JSON {
+			e.Out.Print(output.NewPublishAck(m, pa))
+		} else if pa != nil {
+			e.Log.Printf("Published [%s]: '%s'\nStream: [%s], Seq: [%v]", m.Subject, m.Data, pa.Stream, pa.Sequence)
+		}
+		if !src.Lines() {
+			return nil
+		}
+	}
+}
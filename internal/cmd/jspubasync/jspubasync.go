@@ -0,0 +1,142 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jspubasync publishes a message to a JetStream stream asynchronously.
+package jspubasync
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/payload"
+	"github.com/tbeets/gonats-101/internal/tmpl"
+)
+
+// Command is nats-js-pubasync, also run as "gonats js pub-async".
+var Command = &cli.Command{
+	Name:     "js pub-async",
+	Binary:   "nats-js-pubasync",
+	Usage:    "[-H key:value]... [-headers-file file] [-encoding base64|hex] [-lines] <subject> <msg|@file|->",
+	Short:    "Publish a message to a stream asynchronously",
+	ConnName: "NATS JetStream Sample Publisher",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var headers tmpl.Headers
+		var opts payload.Options
+		headers.AddFlags(fs)
+		opts.AddFlags(fs)
+		return func(e *cli.Env, args []string) error {
+			return pubAsync(e, args, &headers, &opts)
+		}
+	},
+}
+
+func pubAsync(e *cli.Env, args []string, headers *tmpl.Headers, opts *payload.Options) error {
+	if len(args) != 2 {
+		return e.UsageError()
+	}
+	hdr, err := headers.Header()
+	if err != nil {
+		return err
+	}
+	mt, err := tmpl.ParseMsg(args[0], hdr)
+	if err != nil {
+		return err
+	}
+	src, err := opts.Open(args[1], e.Stdin)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// Create JetStream Context from NATS connection
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return err
+	}
+
+	// Asynchronous publish - a publish acknowledgement future is returned
+	// Since JetStreams cannot overlap subject filter namespace, subject is sufficient to publish
+	// into a stream. In line mode, each line is a message, at most maxPending
+	// of them published before awaiting their acknowledgements.
+	var pafs []nats.PubAckFuture
+	for n := 1; ; n++ {
+		data, err := src.Next(n)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := payload.Check(nc, data); err != nil {
+			return err
+		}
+		m, err := mt.Render(n, data)
+		if err != nil {
+			return err
+		}
+		paf, err := js.PublishMsgAsync(m)
+		if err != nil {
+			return err
+		}
+		pafs = append(pafs, paf)
+		if !src.Lines() {
+			break
+		}
+		if len(pafs) == maxPending {
+			if err := awaitAcks(e, pafs); err != nil {
+				return err
+			}
+			pafs = pafs[:0]
+		}
+	}
+	return awaitAcks(e, pafs)
+}
+
+// maxPending is the most messages awaiting their acknowledgement.
+const maxPending = 256
+
+// ackTimeout is how long to wait for each acknowledgement.
+const ackTimeout = 5 * time.Second
+
+// awaitAcks waits for the acknowledgements of pafs, in order, and reports
+// them.
+func awaitAcks(e *cli.Env, pafs []nats.PubAckFuture) error {
+	// Test for an acknowledgement returned from stream
+	for _, paf := range pafs {
+		select {
+		case pa := <-paf.Ok():
+			if e.Out.JSON {
+				e.Out.Print(output.NewPublishAck(paf.Msg(), pa))
+				continue
+			}
+			e.Log.Printf("Published [%s]: '%s'\nStream: [%s], Seq: [%v]", paf.Msg().Subject, paf.Msg().Data, pa.Stream, pa.Sequence)
+		case err := <-paf.Err():
+			return err
+			// e.g. JetStream not available for subject: "nats: no responders available for request"
+		case <-time.After(ackTimeout):
+			return nats.ErrTimeout
+		}
+	}
+	return nil
+}
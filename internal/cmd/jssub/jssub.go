@@ -0,0 +1,154 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jssub receives the messages of a JetStream push consumer.
+package jssub
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/conn"
+	"github.com/tbeets/gonats-101/internal/filter"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/receive"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Command is nats-js-subsds, also run as "gonats js sub".
+var Command = &cli.Command{
+	Name:        "js sub",
+	Binary:      "nats-js-subsds",
+	Usage:       "[-t] [-filter expr]... <stream> <consumer>",
+	Short:       "Receive the messages of a push consumer",
+	ConnName:    "NATS Sample JS Subscriber",
+	LongRunning: true,
+	Metrics:     true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var showTime = fs.Bool("t", false, "Display timestamps")
+		var fo filter.Options
+		fo.AddFlags(fs)
+		return func(e *cli.Env, args []string) error {
+			return sub(e, args, *showTime, fo)
+		}
+	},
+}
+
+func printMsg(e *cli.Env, m *nats.Msg, i int) error {
+	if e.Out.JSON {
+		e.Out.Print(output.NewMessage(m, i))
+		return nil
+	}
+	md, err := m.Metadata()
+	if err != nil {
+		return err
+	}
+	e.Log.Printf("[%d]: %s\nSeqPair: [%v] Pending: [%d]", i, m.Subject, md.Sequence, md.NumPending)
+	return nil
+}
+
+func sub(e *cli.Env, args []string, showTime bool, fo filter.Options) error {
+	if len(args) != 2 {
+		return e.UsageError()
+	}
+	flt, err := fo.Filter()
+	if err != nil {
+		return err
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// Create JetStream Context from NATS connection
+	js, err := e.Conn.JetStream(nc)
+	if err != nil {
+		return err
+	}
+
+	str, con := args[0], args[1]
+
+	// Even though we are using Bind(), the method requires us to specify a subject and queueGroup parameter that
+	// match with the bound Push JS Consumer so we look those up.
+	cfg, err := getConsumerConfig(js, str, con)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	counter := (&receive.Limits{}).Counter()
+	sub, err := js.QueueSubscribe(cfg.FilterSubject, cfg.DeliverGroup, func(msg *nats.Msg) {
+		start := time.Now()
+		e.Metrics.Received(msg)
+		// Messages dropped by the filter are acknowledged all the same.
+		if !flt.Match(msg) {
+			counter.Dropped(msg)
+			if msg.Ack() == nil {
+				e.Metrics.Acks.Inc()
+			}
+			e.Metrics.Handled(start)
+			return
+		}
+		i += 1
+		counter.Received(msg)
+		if err := printMsg(e, msg, i); err != nil {
+			e.Log.Printf("%s", err)
+			if msg.Nak() == nil {
+				e.Metrics.Naks.Inc()
+			}
+		} else if msg.Ack() == nil {
+			e.Metrics.Acks.Inc()
+		}
+		e.Metrics.Handled(start)
+	}, nats.Bind(str, con), nats.ManualAck())
+
+	if err != nil {
+		return err
+	}
+	e.Metrics.WatchConn(nc)
+	e.Metrics.WatchSub(sub)
+
+	if e.Out.JSON {
+		e.Out.Print(output.NewConsumerSubscribed(str, con))
+	} else {
+		e.Log.Printf("Listening on stream [%s], consumer [%s]", str, con)
+	}
+	if showTime {
+		e.Log.SetFlags(log.LstdFlags)
+	}
+
+	// Wait for the interrupt, then drain so we don't miss
+	// requests when scaling down.
+	<-e.Interrupted()
+	if flt != nil {
+		counter.Summarize(e)
+	}
+	return e.Drain(nc)
+}
+
+func getConsumerConfig(js nats.JetStreamContext, str string, con string) (*nats.ConsumerConfig, error) {
+	ci, err := js.ConsumerInfo(str, con)
+	if err != nil {
+		return nil, conn.APIError(err)
+	}
+	if ci.Config.DeliverSubject == "" {
+		return nil, status.Invalidf("JS Consumer [%s] is not an SDS consumer", con)
+	}
+	return &ci.Config, nil
+}
@@ -0,0 +1,116 @@
+// Package microhello is a micro service answering hello requests.
+package microhello
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+)
+
+// Command is microhello, also run as "gonats micro hello".
+var Command = &cli.Command{
+	Name:        "micro hello",
+	Binary:      "microhello",
+	Short:       "Run a micro service answering hello requests",
+	ConnName:    "NATS Micro Hello Service",
+	LongRunning: true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		return hello
+	},
+}
+
+// AddHelloService adds the hello service to nc, reporting its lifecycle to
+// out, or as text to w.
+func AddHelloService(nc *nats.Conn, out *output.Printer, w io.Writer) (micro.Service, error) {
+	helloHandler := func(req *micro.Request) error {
+		req.Respond([]byte(fmt.Sprintf("A hearty micro Hello to ya' [%s]", time.Now().String())))
+		return nil
+	}
+
+	config := micro.Config{
+		Name:        "MicroHelloService",
+		Version:     "1.0.0",
+		Description: "Say hello",
+		Endpoint: micro.Endpoint{
+			Subject: "hello",
+			Handler: helloHandler,
+		},
+
+		// DoneHandler can be set to customize behavior on stopping a service.
+		DoneHandler: func(srv micro.Service) {
+			info := srv.Info()
+			if out.JSON {
+				out.Print(output.NewService("stopped", info.Name, info.ID, info.Version))
+				return
+			}
+			fmt.Fprintf(w, "Stopped service %q with ID %q\n", info.Name, info.ID)
+		},
+
+		// ErrorHandler can be used to customize behavior on service execution error.
+		ErrorHandler: func(srv micro.Service, err *micro.NATSError) {
+			info := srv.Info()
+			if out.JSON {
+				out.Print(output.NewServiceError(info.Name, info.ID, err.Subject, err.Description))
+				return
+			}
+			fmt.Fprintf(w, "Service %q returned an error on subject %q: %s", info.Name, err.Subject, err.Description)
+		},
+	}
+
+	if !out.JSON {
+		fmt.Fprintf(w, "Starting service %q...\n", config.Name)
+	}
+
+	srv, err := micro.AddService(nc, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if out.JSON {
+		info := srv.Info()
+		out.Print(output.NewService("started", info.Name, info.ID, info.Version))
+	} else {
+		fmt.Fprintf(w, "Started service %q with ID %q\n", srv.Info().Name, srv.Info().ID)
+	}
+
+	return srv, nil
+}
+
+func hello(e *cli.Env, args []string) error {
+	if len(args) != 0 {
+		return e.UsageError()
+	}
+
+	if !e.Out.JSON {
+		fmt.Fprintf(e.Stdout, "Starting NATS microservice hosting infrastructure... (CTRL-C to halt)\n")
+	}
+
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	mySvc, err := AddHelloService(nc, e.Out, e.Stdout)
+	if err != nil {
+		return fmt.Errorf("Could not add service: %w", err)
+	}
+
+	<-e.Interrupted()
+	if !e.Out.JSON {
+		fmt.Fprintf(e.Stdout, "\nHalting NATS microservice hosting infrastructure...\n")
+	}
+
+	// Stopping drains the service endpoints, so requests in flight are
+	// answered before the connection closes.
+	if err := mySvc.Stop(); err != nil {
+		return err
+	}
+	return e.Drain(nc)
+}
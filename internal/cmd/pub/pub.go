@@ -0,0 +1,324 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pub publishes a message, or a paced stream of messages, possibly
+// spread over several subjects.
+package pub
+
+import (
+	"errors"
+	"flag"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/payload"
+	"github.com/tbeets/gonats-101/internal/status"
+	"github.com/tbeets/gonats-101/internal/tmpl"
+	"github.com/tbeets/gonats-101/internal/trace"
+)
+
+// NOTE: Can test with demo servers.
+// nats-pub -s demo.nats.io <subject> <msg>
+// nats-pub -s demo.nats.io:4443 <subject> <msg> (TLS version)
+
+// Command is nats-pub, also run as "gonats pub".
+var Command = &cli.Command{
+	Name:        "pub",
+	Binary:      "nats-pub",
+	Usage:       "[-reply subject] [-H key:value]... [-headers-file file] [-count n] [-interval duration | -rate msgs/sec] [-duration duration] [-encoding base64|hex] [-lines] [-distribution round-robin|random|hash] [-key template] <subject>[,subject]... <msg|@file|->",
+	Short:       "Publish a message",
+	ConnName:    "NATS Sample Publisher",
+	LongRunning: true,
+	Trace:       true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var o options
+		fs.StringVar(&o.reply, "reply", "", "Sets a specific reply subject")
+		o.headers.AddFlags(fs)
+		o.payload.AddFlags(fs)
+		fs.IntVar(&o.count, "count", 1, "Number of messages to publish, 0 for no limit")
+		fs.DurationVar(&o.interval, "interval", 0, "Wait between messages")
+		fs.Float64Var(&o.rate, "rate", 0, "Publish at this many messages per second")
+		fs.DurationVar(&o.duration, "duration", 0, "Stop publishing after this long, with no limit on -count unless given")
+		fs.StringVar(&o.distribution, "distribution", "round-robin", "Spread messages over the subjects by round-robin, random or hash of -key")
+		fs.StringVar(&o.key, "key", "", "Template of the key hashed by -distribution hash, the payload if not given")
+		return func(e *cli.Env, args []string) error {
+			counted := false
+			fs.Visit(func(f *flag.Flag) { counted = counted || f.Name == "count" })
+			if (o.duration > 0 || o.payload.Lines) && !counted {
+				o.count = 0
+			}
+			return pub(e, args, o)
+		}
+	},
+}
+
+// options are the publishing options.
+type options struct {
+	reply    string
+	headers  tmpl.Headers
+	payload  payload.Options
+	count    int
+	interval time.Duration
+	rate     float64
+	duration time.Duration
+
+	distribution string
+	key          string
+}
+
+func pub(e *cli.Env, args []string, o options) error {
+	if len(args) != 2 {
+		return e.UsageError()
+	}
+	switch {
+	case o.count < 0 || o.interval < 0 || o.rate < 0 || o.duration < 0:
+		return status.Invalidf("-count, -interval, -rate and -duration must not be negative")
+	case o.interval > 0 && o.rate > 0:
+		return status.Invalidf("specify -interval or -rate")
+	case o.rate > 0:
+		o.interval = time.Duration(float64(time.Second) / o.rate)
+	}
+	hdr, err := o.headers.Header()
+	if err != nil {
+		return err
+	}
+	fo, err := newFanout(args[0], hdr, o.distribution, o.key)
+	if err != nil {
+		return err
+	}
+	src, err := o.payload.Open(args[1], e.Stdin)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// A single message is flushed before being reported as published, a
+	// stream of messages only once done.
+	single := o.count == 1
+	var deadline <-chan time.Time
+	if o.duration > 0 {
+		deadline = time.After(o.duration)
+	}
+
+	start := time.Now()
+	msgs, bytes := 0, 0
+	subjects := map[string]int{}
+	for o.count == 0 || msgs < o.count {
+		var next <-chan time.Time
+		if msgs > 0 && o.interval > 0 {
+			next = time.After(time.Until(start.Add(time.Duration(msgs) * o.interval)))
+		}
+		if !wait(e, deadline, next) {
+			break
+		}
+
+		data, err := src.Next(msgs + 1)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := payload.Check(nc, data); err != nil {
+			return err
+		}
+		mt, err := fo.pick(msgs+1, data)
+		if err != nil {
+			return err
+		}
+		msg, err := mt.Render(msgs+1, data)
+		if err != nil {
+			return err
+		}
+		msg.Reply = o.reply
+		if err := publish(e, nc, msg, single); err != nil {
+			return err
+		}
+		msgs++
+		bytes += len(msg.Data)
+		subjects[msg.Subject]++
+	}
+	if err := nc.Flush(); err != nil {
+		return err
+	}
+	if err := nc.LastError(); err != nil {
+		return err
+	}
+	if single {
+		return nil
+	}
+
+	sum := output.NewPublishSummary(msgs, bytes, time.Since(start))
+	if len(subjects) > 1 {
+		sum.Subjects = subjects
+	}
+	if e.Out.JSON {
+		e.Out.Print(sum)
+		return nil
+	}
+	e.Log.Printf("Published %d msgs, %d bytes in %v (%.1f msgs/sec, %.1f bytes/sec)",
+		sum.Msgs, sum.Bytes, time.Since(start).Round(time.Millisecond), sum.MsgsPerSec, sum.BytesPerSec)
+	names := make([]string, 0, len(sum.Subjects))
+	for subj := range sum.Subjects {
+		names = append(names, subj)
+	}
+	sort.Strings(names)
+	for _, subj := range names {
+		e.Log.Printf("  [%s]: %d msgs", subj, sum.Subjects[subj])
+	}
+	return nil
+}
+
+// fanout spreads the messages published over the subjects given.
+type fanout struct {
+	msgs         []*tmpl.Msg
+	distribution string
+	key          *tmpl.Template
+	rand         *rand.Rand
+}
+
+// newFanout parses the comma separated subject templates of subjects, each
+// message published with headers hdr, spread by distribution.
+func newFanout(subjects string, hdr nats.Header, distribution, key string) (*fanout, error) {
+	fo := &fanout{distribution: distribution}
+	switch distribution {
+	case "round-robin":
+	case "random":
+		fo.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	case "hash":
+		if key != "" {
+			kt, err := tmpl.Parse("key", key)
+			if err != nil {
+				return nil, err
+			}
+			fo.key = kt
+		}
+	default:
+		return nil, status.Invalidf("invalid -distribution %q, use round-robin, random or hash", distribution)
+	}
+	if key != "" && distribution != "hash" {
+		return nil, status.Invalidf("-key needs -distribution hash")
+	}
+	for _, subj := range splitSubjects(subjects) {
+		mt, err := tmpl.ParseMsg(subj, hdr)
+		if err != nil {
+			return nil, err
+		}
+		fo.msgs = append(fo.msgs, mt)
+	}
+	return fo, nil
+}
+
+// pick returns the template of the count'th message published, counting
+// from 1, with payload data.
+func (fo *fanout) pick(count int, data []byte) (*tmpl.Msg, error) {
+	if len(fo.msgs) == 1 {
+		return fo.msgs[0], nil
+	}
+	switch fo.distribution {
+	case "random":
+		return fo.msgs[fo.rand.Intn(len(fo.msgs))], nil
+	case "hash":
+		key := data
+		if fo.key != nil {
+			k, err := fo.key.Render(count)
+			if err != nil {
+				return nil, err
+			}
+			key = []byte(k)
+		}
+		h := fnv.New32a()
+		h.Write(key)
+		return fo.msgs[h.Sum32()%uint32(len(fo.msgs))], nil
+	}
+	return fo.msgs[(count-1)%len(fo.msgs)], nil
+}
+
+// splitSubjects splits s on the commas outside of template actions.
+func splitSubjects(s string) []string {
+	var subjects []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{"):
+			depth++
+			i++
+		case strings.HasPrefix(s[i:], "}}") && depth > 0:
+			depth--
+			i++
+		case s[i] == ',' && depth == 0:
+			subjects = append(subjects, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(subjects, s[start:])
+}
+
+// wait waits for next, unless nil, and reports whether to go on publishing:
+// not once past the deadline or interrupted.
+func wait(e *cli.Env, deadline, next <-chan time.Time) bool {
+	select {
+	case <-deadline:
+		return false
+	case <-e.Interrupted():
+		return false
+	default:
+	}
+	if next == nil {
+		return true
+	}
+	select {
+	case <-deadline:
+		return false
+	case <-e.Interrupted():
+		return false
+	case <-next:
+		return true
+	}
+}
+
+// publish publishes msg, flushing it first if flush is set, and reports it.
+func publish(e *cli.Env, nc *nats.Conn, msg *nats.Msg, flush bool) error {
+	span := e.Tracer.Start("publish", trace.KindProducer, msg.Subject)
+	span.Inject(msg)
+	err := nc.PublishMsg(msg)
+	if err == nil && flush {
+		err = nc.Flush()
+	}
+	if err == nil {
+		err = nc.LastError()
+	}
+	span.Finish(err)
+
+	if err != nil {
+		return err
+	} else if e.Out.JSON {
+		e.Out.Print(output.NewPublished(msg))
+	} else {
+		e.Log.Printf("Published [%s] : '%s'\n", msg.Subject, msg.Data)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qsub subscribes to a subject in a queue group and prints the
+// messages received.
+package qsub
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/filter"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/receive"
+)
+
+// NOTE: Can test with demo servers.
+// nats-qsub -s demo.nats.io <subject> <queue>
+// nats-qsub -s demo.nats.io:4443 <subject> <queue> (TLS version)
+
+// Command is nats-qsub, also run as "gonats qsub".
+var Command = &cli.Command{
+	Name:        "qsub",
+	Binary:      "nats-qsub",
+	Usage:       "[-t] [-count n] [-timeout duration] [-idle duration] [-filter expr]... <subject> <queue>",
+	Short:       "Subscribe to a subject in a queue group and print the messages received",
+	ConnName:    "NATS Sample Queue Subscriber",
+	LongRunning: true,
+	Metrics:     true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var showTime = fs.Bool("t", false, "Display timestamps")
+		var l receive.Limits
+		l.AddFlags(fs)
+		var fo filter.Options
+		fo.AddFlags(fs)
+		return func(e *cli.Env, args []string) error {
+			return qsub(e, args, *showTime, l, fo)
+		}
+	},
+}
+
+func printMsg(e *cli.Env, m *nats.Msg, i int) {
+	if e.Out.JSON {
+		e.Out.Print(output.NewMessage(m, i))
+		return
+	}
+	e.Log.Printf("[#%d] Received on [%s] Queue[%s] Pid[%d]: '%s'", i, m.Subject, m.Sub.Queue, os.Getpid(), string(m.Data))
+}
+
+func qsub(e *cli.Env, args []string, showTime bool, l receive.Limits, fo filter.Options) error {
+	if len(args) != 2 {
+		return e.UsageError()
+	}
+	if err := l.Check(); err != nil {
+		return err
+	}
+	flt, err := fo.Filter()
+	if err != nil {
+		return err
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	subj, queue, i := args[0], args[1], 0
+	counter := l.Counter()
+
+	sub, err := nc.QueueSubscribe(subj, queue, func(msg *nats.Msg) {
+		start := time.Now()
+		e.Metrics.Received(msg)
+		if counter.Done() {
+			e.Metrics.Handled(start)
+			return
+		}
+		if !flt.Match(msg) {
+			counter.Dropped(msg)
+			e.Metrics.Handled(start)
+			return
+		}
+		i++
+		printMsg(e, msg, i)
+		counter.Received(msg)
+		e.Metrics.Handled(start)
+	})
+	if err != nil {
+		return err
+	}
+	if err := counter.Add(sub, flt != nil); err != nil {
+		return err
+	}
+	e.Metrics.WatchConn(nc)
+	e.Metrics.WatchSub(sub)
+	nc.Flush()
+
+	if err := nc.LastError(); err != nil {
+		return err
+	}
+
+	if e.Out.JSON {
+		e.Out.Print(output.NewSubscribed(subj, queue))
+	} else {
+		e.Log.Printf("Listening on [%s], queue group [%s]", subj, queue)
+	}
+	if showTime {
+		e.Log.SetFlags(log.LstdFlags)
+	}
+
+	// Wait for the interrupt, or an exit condition, then drain so we
+	// don't miss requests when scaling down.
+	if !l.Set() && flt == nil {
+		<-e.Interrupted()
+		return e.Drain(nc)
+	}
+	err = counter.Wait(e)
+	counter.Summarize(e)
+	if derr := e.Drain(nc); err == nil {
+		err = derr
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay publishes the messages of capture files again.
+package replay
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/capture"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/payload"
+	"github.com/tbeets/gonats-101/internal/status"
+	"github.com/tbeets/gonats-101/internal/subject"
+)
+
+// Command is nats-replay, also run as "gonats replay".
+var Command = &cli.Command{
+	Name:        "replay",
+	Binary:      "nats-replay",
+	Usage:       "[-speed factor] [-map 'from -> to']... [-js] <file|->...",
+	Short:       "Publish the messages of capture files again",
+	ConnName:    "NATS Sample Replayer",
+	LongRunning: true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var o options
+		fs.Float64Var(&o.speed, "speed", 1, "Replay this many times faster than captured, 0 for as fast as possible")
+		fs.Var(&o.maps, "map", "Rewrite subjects matching a pattern, such as 'prod.> -> staging.>', may be repeated")
+		fs.BoolVar(&o.js, "js", false, "Publish to JetStream, waiting for each acknowledgement")
+		return func(e *cli.Env, args []string) error {
+			return replay(e, args, o)
+		}
+	},
+}
+
+// options are the replay options.
+type options struct {
+	speed float64
+	maps  subject.List
+	js    bool
+}
+
+func replay(e *cli.Env, args []string, o options) error {
+	if len(args) == 0 {
+		return e.UsageError()
+	}
+	if o.speed < 0 {
+		return status.Invalidf("-speed must not be negative")
+	}
+	var rules []subject.Rule
+	for _, m := range o.maps {
+		r, err := subject.ParseRule(m)
+		if err != nil {
+			return status.Invalidf("-map: %v", err)
+		}
+		rules = append(rules, r)
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	var js nats.JetStreamContext
+	if o.js {
+		if js, err = e.Conn.JetStream(nc); err != nil {
+			return err
+		}
+	}
+
+	rp := &replayer{e: e, nc: nc, js: js, speed: o.speed, rules: rules, start: time.Now(), subjects: map[string]int{}}
+	for _, file := range args {
+		r, err := capture.Open(file, e.Stdin)
+		if err != nil {
+			return err
+		}
+		err = rp.replay(r)
+		r.Close()
+		if errors.Is(err, errInterrupted) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if err := nc.Flush(); err != nil {
+		return err
+	}
+	if err := nc.LastError(); err != nil {
+		return err
+	}
+
+	sum := output.NewPublishSummary(rp.msgs, rp.bytes, time.Since(rp.start))
+	if len(rp.subjects) > 1 {
+		sum.Subjects = rp.subjects
+	}
+	if e.Out.JSON {
+		e.Out.Print(sum)
+		return nil
+	}
+	e.Log.Printf("Replayed %d msgs, %d bytes in %v (%.1f msgs/sec, %.1f bytes/sec)",
+		sum.Msgs, sum.Bytes, time.Since(rp.start).Round(time.Millisecond), sum.MsgsPerSec, sum.BytesPerSec)
+	names := make([]string, 0, len(sum.Subjects))
+	for subj := range sum.Subjects {
+		names = append(names, subj)
+	}
+	sort.Strings(names)
+	for _, subj := range names {
+		e.Log.Printf("  [%s]: %d msgs", subj, sum.Subjects[subj])
+	}
+	return nil
+}
+
+// errInterrupted stops the replay once interrupted.
+var errInterrupted = errors.New("interrupted")
+
+// replayer publishes the messages of capture files, at their offset from the
+// first message captured divided by the speed, or as fast as possible.
+type replayer struct {
+	e     *cli.Env
+	nc    *nats.Conn
+	js    nats.JetStreamContext
+	speed float64
+	rules []subject.Rule
+
+	start    time.Time
+	first    time.Time
+	msgs     int
+	bytes    int
+	subjects map[string]int
+}
+
+// replay publishes the messages of r.
+func (rp *replayer) replay(r *capture.Reader) error {
+	for {
+		cm, m, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if rp.first.IsZero() {
+			rp.first = cm.Time
+		}
+		var next <-chan time.Time
+		if rp.speed > 0 {
+			offset := time.Duration(float64(cm.Time.Sub(rp.first)) / rp.speed)
+			next = time.After(time.Until(rp.start.Add(offset)))
+		}
+		if !wait(rp.e, next) {
+			return errInterrupted
+		}
+
+		for _, rule := range rp.rules {
+			if subj, ok := rule.Map(m.Subject); ok {
+				m.Subject = subj
+				break
+			}
+		}
+		if err := payload.Check(rp.nc, m.Data); err != nil {
+			return err
+		}
+		if err := rp.publish(m); err != nil {
+			return err
+		}
+		rp.msgs++
+		rp.bytes += len(m.Data)
+		rp.subjects[m.Subject]++
+	}
+}
+
+// wait waits for next, unless nil, and reports whether to go on replaying:
+// not once interrupted.
+func wait(e *cli.Env, next <-chan time.Time) bool {
+	select {
+	case <-e.Interrupted():
+		return false
+	default:
+	}
+	if next == nil {
+		return true
+	}
+	select {
+	case <-e.Interrupted():
+		return false
+	case <-next:
+		return true
+	}
+}
+
+// publish publishes m, to JetStream with -js, and reports it.
+func (rp *replayer) publish(m *nats.Msg) error {
+	e := rp.e
+	if rp.js == nil {
+		if err := rp.nc.PublishMsg(m); err != nil {
+			return err
+		}
+		if e.Out.JSON {
+			e.Out.Print(output.NewPublished(m))
+		} else {
+			e.Log.Printf("Published [%s] : '%s'", m.Subject, m.Data)
+		}
+		return nil
+	}
+	pa, err := rp.js.PublishMsg(m)
+	if err != nil {
+		return err
+	}
+	if e.Out.JSON {
+		e.Out.Print(output.NewPublishAck(m, pa))
+	} else {
+		e.Log.Printf("Published [%s]: '%s'\nStream: [%s], Seq: [%v]", m.Subject, m.Data, pa.Stream, pa.Sequence)
+	}
+	return nil
+}
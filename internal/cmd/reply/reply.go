@@ -0,0 +1,112 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reply answers the requests on a subject with a fixed response.
+package reply
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/trace"
+)
+
+// NOTE: Can test with demo servers.
+// nats-rply -s demo.nats.io <subject> <response>
+// nats-rply -s demo.nats.io:4443 <subject> <response> (TLS version)
+
+// Command is nats-rply, also run as "gonats reply".
+var Command = &cli.Command{
+	Name:        "reply",
+	Binary:      "nats-rply",
+	Usage:       "[-t] [-q queue] <subject> <response>",
+	Short:       "Answer the requests on a subject with a fixed response",
+	ConnName:    "NATS Sample Responder",
+	LongRunning: true,
+	Metrics:     true,
+	Trace:       true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var showTime = fs.Bool("t", false, "Display timestamps")
+		var queueName = fs.String("q", "NATS-RPLY-22", "Queue Group Name")
+		return func(e *cli.Env, args []string) error {
+			return reply(e, args, *showTime, *queueName)
+		}
+	},
+}
+
+func printMsg(e *cli.Env, m *nats.Msg, i int) {
+	if e.Out.JSON {
+		e.Out.Print(output.NewMessage(m, i))
+		return
+	}
+	e.Log.Printf("[#%d] Received on [%s]: '%s'\n", i, m.Subject, string(m.Data))
+}
+
+func reply(e *cli.Env, args []string, showTime bool, queueName string) error {
+	if len(args) < 2 {
+		return e.UsageError()
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	subj, reply, i := args[0], args[1], 0
+
+	sub, err := nc.QueueSubscribe(subj, queueName, func(msg *nats.Msg) {
+		start := time.Now()
+		e.Metrics.Received(msg)
+		span := e.Tracer.Continue("reply", trace.KindServer, msg)
+		i++
+		printMsg(e, msg, i)
+		resp := &nats.Msg{Data: []byte(reply)}
+		span.Inject(resp)
+		err := msg.RespondMsg(resp)
+		if err == nil {
+			e.Metrics.Sent(resp.Data)
+		}
+		span.Finish(err)
+		e.Metrics.Handled(start)
+	})
+	if err != nil {
+		return err
+	}
+	e.Metrics.WatchConn(nc)
+	e.Metrics.WatchSub(sub)
+	nc.Flush()
+
+	if err := nc.LastError(); err != nil {
+		return err
+	}
+
+	if e.Out.JSON {
+		e.Out.Print(output.NewSubscribed(subj, queueName))
+	} else {
+		e.Log.Printf("Listening on [%s]", subj)
+	}
+	if showTime {
+		e.Log.SetFlags(log.LstdFlags)
+	}
+
+	// Wait for the interrupt, then drain so we don't miss
+	// requests when scaling down.
+	<-e.Interrupted()
+	return e.Drain(nc)
+}
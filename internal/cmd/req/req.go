@@ -0,0 +1,118 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package req sends a request and prints the reply.
+package req
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/payload"
+	"github.com/tbeets/gonats-101/internal/tmpl"
+	"github.com/tbeets/gonats-101/internal/trace"
+)
+
+// NOTE: Can test with demo servers.
+// nats-req -s demo.nats.io <subject> <msg>
+// nats-req -s demo.nats.io:4443 <subject> <msg> (TLS version)
+
+// Command is nats-req, also run as "gonats req".
+var Command = &cli.Command{
+	Name:     "req",
+	Binary:   "nats-req",
+	Usage:    "[-H key:value]... [-headers-file file] [-encoding base64|hex] [-lines] <subject> <msg|@file|->",
+	Short:    "Send a request and print the reply",
+	ConnName: "NATS Sample Requestor",
+	Trace:    true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var headers tmpl.Headers
+		var opts payload.Options
+		headers.AddFlags(fs)
+		opts.AddFlags(fs)
+		return func(e *cli.Env, args []string) error {
+			return req(e, args, &headers, &opts)
+		}
+	},
+}
+
+func req(e *cli.Env, args []string, headers *tmpl.Headers, opts *payload.Options) error {
+	if len(args) < 2 {
+		return e.UsageError()
+	}
+	hdr, err := headers.Header()
+	if err != nil {
+		return err
+	}
+	src, err := opts.Open(args[1], e.Stdin)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// In line mode, each line is a request.
+	for n := 1; ; n++ {
+		data, err := src.Next(n)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := payload.Check(nc, data); err != nil {
+			return err
+		}
+		if err := request(e, nc, &nats.Msg{Subject: args[0], Header: hdr, Data: data}); err != nil {
+			return err
+		}
+		if !src.Lines() {
+			return nil
+		}
+	}
+}
+
+// request sends reqMsg and reports the reply.
+func request(e *cli.Env, nc *nats.Conn, reqMsg *nats.Msg) error {
+	start := time.Now()
+	span := e.Tracer.Start("request", trace.KindClient, reqMsg.Subject)
+	span.Inject(reqMsg)
+	msg, err := nc.RequestMsg(reqMsg, 2*time.Second)
+	span.Finish(err)
+	if err != nil {
+		if nc.LastError() != nil {
+			return fmt.Errorf("%w for request", nc.LastError())
+		}
+		return fmt.Errorf("%w for request", err)
+	}
+
+	if e.Out.JSON {
+		e.Out.Print(output.NewReply(reqMsg.Subject, msg, time.Since(start)))
+		return nil
+	}
+
+	e.Log.Printf("Published [%s] : '%s'", reqMsg.Subject, reqMsg.Data)
+	e.Log.Printf("Received  [%v] : '%s'", msg.Subject, string(msg.Data))
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reqmulti sends a request and prints every reply received within
+// a time limit.
+package reqmulti
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/tmpl"
+)
+
+// Command is nats-req-multi, also run as "gonats req-multi".
+var Command = &cli.Command{
+	Name:     "req-multi",
+	Binary:   "nats-req-multi",
+	Usage:    "[-d {reply duration}] [-m {max replies}] [-H key:value]... [-headers-file file] <subject> <msg>",
+	Short:    "Send a request and print every reply received",
+	ConnName: "NATS Sample Requestor",
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var duration = fs.Int("d", 2, "Reply interest duration (seconds)")
+		var max = fs.Int("m", 1, "Maximum number of replies")
+		var headers tmpl.Headers
+		headers.AddFlags(fs)
+		return func(e *cli.Env, args []string) error {
+			return reqMulti(e, args, *duration, *max, &headers)
+		}
+	},
+}
+
+func doReqWait(e *cli.Env, nc *nats.Conn, subj string, hdr nats.Header, body []byte, dur int, max int) error {
+	start := time.Now()
+	countCh := make(chan struct{}, 128)
+
+	msg := nats.Msg{
+		Subject: subj,
+		Reply:   nc.NewRespInbox(),
+		Header:  hdr,
+		Data:    body,
+		Sub:     nil,
+	}
+
+	s, err := nc.Subscribe(msg.Reply, func(m *nats.Msg) {
+		countCh <- struct{}{}
+
+		if e.Out.JSON {
+			e.Out.Print(output.NewReply(subj, m, time.Since(start)))
+			return
+		}
+
+		e.Log.Printf("Received on %q rtt %v", m.Subject, time.Since(start))
+
+		if len(m.Header) > 0 {
+			for h, vals := range m.Header {
+				for _, val := range vals {
+					e.Log.Printf("%s: %s", h, val)
+				}
+			}
+
+			fmt.Fprintln(e.Stdout)
+		}
+
+		fmt.Fprintln(e.Stdout, string(m.Data))
+		if !strings.HasSuffix(string(m.Data), "\n") {
+			fmt.Fprintln(e.Stdout)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer s.Unsubscribe()
+
+	err = nc.PublishMsg(&msg)
+	if err != nil {
+		return err
+	}
+
+	received := 0
+Loop:
+	for {
+		select {
+		case <-countCh:
+			received++
+			if received >= max {
+				break Loop
+			}
+		case <-time.After(time.Duration(dur) * time.Second):
+			break Loop
+		}
+	}
+
+	// we don't want any responses after we break.
+	s.Unsubscribe()
+
+	return nil
+}
+
+func reqMulti(e *cli.Env, args []string, duration, max int, headers *tmpl.Headers) error {
+	if len(args) < 2 {
+		return e.UsageError()
+	}
+	hdr, err := headers.Header()
+	if err != nil {
+		return err
+	}
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	subj, payload := args[0], []byte(args[1])
+
+	if !e.Out.JSON {
+		e.Log.Printf("Published [%s] : '%s'", subj, payload)
+	}
+
+	// msg, err := nc.Request(subj, payload, 2*time.Second)
+	err = doReqWait(e, nc, subj, hdr, payload, duration, max)
+	if err != nil {
+		if nc.LastError() != nil {
+			return fmt.Errorf("%w for request", nc.LastError())
+		}
+		return fmt.Errorf("%w for request", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2012-2021 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sub subscribes to one or more subjects and prints the messages
+// received.
+package sub
+
+import (
+	"flag"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/capture"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/filter"
+	"github.com/tbeets/gonats-101/internal/format"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/receive"
+	"github.com/tbeets/gonats-101/internal/status"
+	"github.com/tbeets/gonats-101/internal/subject"
+	"github.com/tbeets/gonats-101/internal/trace"
+)
+
+// NOTE: Can test with demo servers.
+// nats-sub -s demo.nats.io <subject>
+// nats-sub -s demo.nats.io:4443 <subject> (TLS version)
+
+// Command is nats-sub, also run as "gonats sub".
+var Command = &cli.Command{
+	Name:        "sub",
+	Binary:      "nats-sub",
+	Usage:       "[-t] [-count n] [-timeout duration] [-idle duration] [-exclude subject]... [-filter expr]... [-format text|raw|json|hex|template] [-capture file [-capture-size bytes] [-capture-age duration] [-capture-gzip]] <subject>...",
+	Short:       "Subscribe to subjects and print the messages received",
+	ConnName:    "NATS Sample Subscriber",
+	LongRunning: true,
+	Metrics:     true,
+	Trace:       true,
+	Flags: func(fs *flag.FlagSet) func(*cli.Env, []string) error {
+		var o options
+		fs.BoolVar(&o.showTime, "t", false, "Display timestamps")
+		o.limits.AddFlags(fs)
+		fs.Var(&o.exclude, "exclude", "Ignore the messages on subjects matching this subject, wildcards included, may be repeated")
+		o.filter.AddFlags(fs)
+		o.format.AddFlags(fs)
+		o.capture.AddFlags(fs)
+		return func(e *cli.Env, args []string) error {
+			return sub(e, args, o)
+		}
+	},
+}
+
+// options are the subscribing options.
+type options struct {
+	showTime bool
+	limits   receive.Limits
+	exclude  subject.List
+	filter   filter.Options
+	format   format.Options
+	capture  capture.Options
+}
+
+func printMsg(e *cli.Env, f *format.Formatter, m *nats.Msg, i int) {
+	if e.Out.JSON {
+		e.Out.Print(output.NewMessage(m, i))
+		return
+	}
+	if !f.Text() {
+		if err := f.Write(m, i); err != nil {
+			e.Log.Printf("Writing message: %v", err)
+		}
+		return
+	}
+	// e.Log.Printf("[#%d] Received on [%s]: '%s'", i, m.Subject, string(m.Data))
+	e.Log.Printf("[#%d] Received on [%s]:", i, m.Subject)
+	msgHeaders := m.Header
+	for headerName, headerValue := range msgHeaders {
+		e.Log.Printf("Header: %s: %s", headerName, headerValue[0:])
+	}
+	e.Log.Printf("Body: '%s'\n", string(m.Data))
+}
+
+func sub(e *cli.Env, args []string, o options) error {
+	if len(args) == 0 {
+		return e.UsageError()
+	}
+	// A message on subjects overlapping would be delivered, and handled,
+	// once per subscription.
+	for i, a := range args {
+		for _, b := range args[:i] {
+			if subject.Overlap(a, b) {
+				return status.Invalidf("subjects [%s] and [%s] overlap, subscribe to one of them, with -exclude", b, a)
+			}
+		}
+	}
+	l := o.limits
+	if err := l.Check(); err != nil {
+		return err
+	}
+	f, err := o.format.Formatter(e.Stdout)
+	if err != nil {
+		return err
+	}
+	if e.Out.JSON && !f.Text() {
+		return status.Invalidf("specify -json or -format")
+	}
+	flt, err := o.filter.Filter()
+	if err != nil {
+		return err
+	}
+	cw, err := o.capture.Open()
+	if err != nil {
+		return err
+	}
+	defer cw.Close()
+
+	// Connect to NATS
+	nc, err := e.Conn.Connect()
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	// The subscriptions each have their own goroutine, so messages are
+	// handled one at a time.
+	var mu sync.Mutex
+	i := 0
+	counter := l.Counter()
+	handler := func(msg *nats.Msg) {
+		start := time.Now()
+		e.Metrics.Received(msg)
+		mu.Lock()
+		defer mu.Unlock()
+		if counter.Done() {
+			e.Metrics.Handled(start)
+			return
+		}
+		if o.exclude.Any(msg.Subject) {
+			counter.Excluded(msg)
+			e.Metrics.Handled(start)
+			return
+		}
+		if !flt.Match(msg) {
+			counter.Dropped(msg)
+			e.Metrics.Handled(start)
+			return
+		}
+		span := e.Tracer.Continue("receive", trace.KindConsumer, msg)
+		i += 1
+		printMsg(e, f, msg, i)
+		if err := cw.Write(msg, i); err != nil {
+			e.Log.Printf("Capturing message: %v", err)
+		}
+		counter.Received(msg)
+		span.Finish(nil)
+		e.Metrics.Handled(start)
+	}
+
+	for _, subj := range args {
+		s, err := nc.Subscribe(subj, handler)
+		if err != nil {
+			return err
+		}
+		if err := counter.Add(s, len(o.exclude) > 0 || flt != nil); err != nil {
+			return err
+		}
+		e.Metrics.WatchSub(s)
+	}
+	e.Metrics.WatchConn(nc)
+	nc.Flush()
+
+	if err := nc.LastError(); err != nil {
+		return err
+	}
+
+	for _, subj := range args {
+		if e.Out.JSON {
+			e.Out.Print(output.NewSubscribed(subj, ""))
+		} else {
+			e.Log.Printf("Listening on [%s]", subj)
+		}
+	}
+	if o.showTime {
+		e.Log.SetFlags(log.LstdFlags)
+	}
+
+	// Wait for the interrupt, or an exit condition, then exit once drained.
+	if !l.Set() && len(args) == 1 && len(o.exclude) == 0 && flt == nil {
+		<-e.Interrupted()
+		return e.Drain(nc)
+	}
+	err = counter.Wait(e)
+	counter.Summarize(e)
+	if derr := e.Drain(nc); err == nil {
+		err = derr
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// CheckAuth verifies that at most one authentication method is given, and
+// that it is given completely.
+func (o *Options) CheckAuth() error {
+	if o.JWT != "" && o.Seed == "" || o.JWT == "" && o.Seed != "" {
+		return status.Invalidf("specify -jwt and -seed together")
+	}
+	if o.Password != "" && o.User == "" {
+		return status.Invalidf("specify -user with -password")
+	}
+
+	var methods []string
+	if o.UserCreds != "" {
+		methods = append(methods, "-creds")
+	}
+	if o.NkeyFile != "" {
+		methods = append(methods, "-nkey")
+	}
+	if o.Seed != "" {
+		methods = append(methods, "-seed")
+	}
+	if o.User != "" {
+		methods = append(methods, "-user")
+	}
+	if o.Token != "" {
+		methods = append(methods, "-token")
+	}
+	if urlHasUserInfo(o.URLs) {
+		methods = append(methods, "credentials in -s")
+	}
+	if len(methods) > 1 {
+		return status.Invalidf("specify %s or %s", methods[0], methods[1])
+	}
+	return nil
+}
+
+// authOptions returns the nats.Options for the authentication method given.
+func (o *Options) authOptions() ([]nats.Option, error) {
+	if err := o.CheckAuth(); err != nil {
+		return nil, err
+	}
+
+	var opts []nats.Option
+
+	// Use UserCredentials
+	if o.UserCreds != "" {
+		opts = append(opts, nats.UserCredentials(o.UserCreds))
+	}
+
+	// Use Nkey authentication.
+	if o.NkeyFile != "" {
+		opt, err := nats.NkeyOptionFromSeed(o.NkeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+
+	// Use a user JWT and its nkey seed given inline.
+	if o.JWT != "" {
+		opts = append(opts, nats.UserJWTAndSeed(o.JWT, o.Seed))
+	}
+
+	// Use username and password.
+	if o.User != "" {
+		opts = append(opts, nats.UserInfo(o.User, o.Password))
+	}
+
+	// Use token authentication.
+	if o.Token != "" {
+		opts = append(opts, nats.Token(o.Token))
+	}
+
+	// Credentials embedded in the URLs are used by nats.Connect directly.
+	return opts, nil
+}
+
+// urlHasUserInfo reports whether any of the server URLs embeds a user,
+// password or token.
+func urlHasUserInfo(urls string) bool {
+	for _, s := range strings.Split(urls, ",") {
+		if u, err := url.Parse(strings.TrimSpace(s)); err == nil && u.User != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// stripUserInfo removes any user or token from the server URLs, when the
+// credentials come from elsewhere.
+func stripUserInfo(urls string) string {
+	list := strings.Split(urls, ",")
+	for i, s := range list {
+		if u, err := url.Parse(strings.TrimSpace(s)); err == nil && u.User != nil {
+			u.User = nil
+			list[i] = u.String()
+		}
+	}
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"flag"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/natscontext"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Sources of a resolved setting, in order of precedence.
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceContext = "context"
+	SourceDefault = "default"
+)
+
+// ContextEnv names the environment variable selecting the context.
+const ContextEnv = "NATS_CONTEXT"
+
+const redacted = "[REDACTED]"
+
+// setting ties a connection flag to the field it sets, its environment
+// variable and the context entry, if any, it may be loaded from. Authentication
+// settings are resolved as a group, so that a credential given at a higher
+// precedence replaces, rather than combines with, one given at a lower.
+type setting struct {
+	flag   string
+	env    string
+	auth   bool
+	secret bool
+	get    func(o *Options) string
+	set    func(o *Options, v string) error
+	ctx    func(c *natscontext.Context) string
+}
+
+// givesAuth reports whether v, the value of s, gives a credential: that of an
+// authentication setting, or server URLs with a user or token.
+func (s setting) givesAuth(v string) bool {
+	if s.flag == "s" {
+		return urlHasUserInfo(v)
+	}
+	return s.auth && v != ""
+}
+
+func stringSetting(flag, env string, auth bool, field func(o *Options) *string, ctx func(c *natscontext.Context) string) setting {
+	return setting{
+		flag: flag,
+		env:  env,
+		auth: auth,
+		get:  func(o *Options) string { return *field(o) },
+		set:  func(o *Options, v string) error { *field(o) = v; return nil },
+		ctx:  ctx,
+	}
+}
+
+func boolSetting(flag, env string, field func(o *Options) *bool) setting {
+	return setting{
+		flag: flag,
+		env:  env,
+		get:  func(o *Options) string { return strconv.FormatBool(*field(o)) },
+		set: func(o *Options, v string) (err error) {
+			*field(o), err = strconv.ParseBool(v)
+			return err
+		},
+	}
+}
+
+func intSetting(flag, env string, field func(o *Options) *int) setting {
+	return setting{
+		flag: flag,
+		env:  env,
+		get:  func(o *Options) string { return strconv.Itoa(*field(o)) },
+		set: func(o *Options, v string) (err error) {
+			*field(o), err = strconv.Atoi(v)
+			return err
+		},
+	}
+}
+
+func durationSetting(flag, env string, field func(o *Options) *time.Duration) setting {
+	return setting{
+		flag: flag,
+		env:  env,
+		get:  func(o *Options) string { return field(o).String() },
+		set: func(o *Options, v string) (err error) {
+			*field(o), err = time.ParseDuration(v)
+			return err
+		},
+	}
+}
+
+func secretSetting(flag, env string, field func(o *Options) *string, ctx func(c *natscontext.Context) string) setting {
+	s := stringSetting(flag, env, true, field, ctx)
+	s.secret = true
+	return s
+}
+
+var settings = []setting{
+	stringSetting("s", "NATS_URL", false,
+		func(o *Options) *string { return &o.URLs }, func(c *natscontext.Context) string { return c.URL }),
+	stringSetting("creds", "NATS_CREDS", true,
+		func(o *Options) *string { return &o.UserCreds }, func(c *natscontext.Context) string { return c.Creds }),
+	stringSetting("nkey", "NATS_NKEY", true,
+		func(o *Options) *string { return &o.NkeyFile }, func(c *natscontext.Context) string { return c.Nkey }),
+	secretSetting("jwt", "NATS_JWT",
+		func(o *Options) *string { return &o.JWT }, func(c *natscontext.Context) string { return c.JWT }),
+	secretSetting("seed", "NATS_SEED",
+		func(o *Options) *string { return &o.Seed }, func(c *natscontext.Context) string { return c.Seed }),
+	stringSetting("user", "NATS_USER", true,
+		func(o *Options) *string { return &o.User }, func(c *natscontext.Context) string { return c.User }),
+	secretSetting("password", "NATS_PASSWORD",
+		func(o *Options) *string { return &o.Password }, func(c *natscontext.Context) string { return c.Password }),
+	secretSetting("token", "NATS_TOKEN",
+		func(o *Options) *string { return &o.Token }, func(c *natscontext.Context) string { return c.Token }),
+	boolSetting("tls", "NATS_TLS", func(o *Options) *bool { return &o.TLS }),
+	stringSetting("tlscert", "NATS_CERT", false,
+		func(o *Options) *string { return &o.TLSClientCert }, func(c *natscontext.Context) string { return c.TLSCert }),
+	stringSetting("tlskey", "NATS_KEY", false,
+		func(o *Options) *string { return &o.TLSClientKey }, func(c *natscontext.Context) string { return c.TLSKey }),
+	stringSetting("tlscacert", "NATS_CA", false,
+		func(o *Options) *string { return &o.TLSCACert }, func(c *natscontext.Context) string { return c.TLSCA }),
+	stringSetting("domain", "NATS_JS_DOMAIN", false,
+		func(o *Options) *string { return &o.JSDomain }, func(c *natscontext.Context) string { return c.JSDomain }),
+	durationSetting("reconnect-wait", "NATS_RECONNECT_WAIT", func(o *Options) *time.Duration { return &o.ReconnectWait }),
+	durationSetting("reconnect-jitter", "NATS_RECONNECT_JITTER", func(o *Options) *time.Duration { return &o.ReconnectJitter }),
+	durationSetting("reconnect-jitter-tls", "NATS_RECONNECT_JITTER_TLS", func(o *Options) *time.Duration { return &o.ReconnectJitterTLS }),
+	intSetting("max-reconnects", "NATS_MAX_RECONNECTS", func(o *Options) *int { return &o.MaxReconnects }),
+	intSetting("reconnect-buf-size", "NATS_RECONNECT_BUF_SIZE", func(o *Options) *int { return &o.ReconnectBufSize }),
+	boolSetting("retry-connect", "NATS_RETRY_CONNECT", func(o *Options) *bool { return &o.RetryConnect }),
+	durationSetting("drain-timeout", "NATS_DRAIN_TIMEOUT", func(o *Options) *time.Duration { return &o.DrainTimeout }),
+	stringSetting("events", "NATS_EVENTS", false, func(o *Options) *string { return &o.EventsFile }, nil),
+}
+
+// layer is one source of settings below the command line.
+type layer struct {
+	source string
+	value  func(s setting) string
+}
+
+// resolve fills in each setting not given as a flag from, in order, its
+// environment variable, the context, or its default.
+func (o *Options) resolve() error {
+	if o.resolved {
+		return nil
+	}
+	o.resolved = true
+	o.sources = map[string]string{}
+
+	set := map[string]bool{}
+	if o.fs != nil {
+		o.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	}
+
+	// Pick the context: -context, then $NATS_CONTEXT, then the selected one.
+	ctx := &natscontext.Context{}
+	o.sources["context"] = SourceFlag
+	if o.Context == "" {
+		o.Context, o.sources["context"] = os.Getenv(ContextEnv), SourceEnv
+	}
+	if o.Context == "" {
+		sel, err := natscontext.Selected()
+		if err != nil {
+			return err
+		}
+		o.Context, o.sources["context"] = sel, SourceDefault
+	}
+	if o.Context != "" {
+		c, err := natscontext.Load(o.Context)
+		if err != nil {
+			return err
+		}
+		ctx = c
+	}
+
+	layers := []layer{
+		{SourceEnv, func(s setting) string { return os.Getenv(s.env) }},
+		{SourceContext, func(s setting) string {
+			if s.ctx == nil {
+				return ""
+			}
+			return s.ctx(ctx)
+		}},
+	}
+
+	// Only the highest precedence source giving any credential, including
+	// those in server URLs, supplies them.
+	authSource := ""
+	for _, s := range settings {
+		if set[s.flag] && s.givesAuth(s.get(o)) {
+			authSource = SourceFlag
+		}
+	}
+	for _, l := range layers {
+		for _, s := range settings {
+			if authSource == "" && s.givesAuth(l.value(s)) {
+				authSource = l.source
+			}
+		}
+	}
+
+	for _, s := range settings {
+		o.sources[s.flag] = SourceDefault
+		if set[s.flag] {
+			o.sources[s.flag] = SourceFlag
+			continue
+		}
+		for _, l := range layers {
+			v := l.value(s)
+			if v == "" || (s.auth && authSource != l.source) {
+				continue
+			}
+			if s.flag == "s" && authSource != l.source {
+				v = stripUserInfo(v)
+			}
+			if err := s.set(o, v); err != nil {
+				return status.Invalidf("%s from %s: %w", s.flag, l.source, err)
+			}
+			o.sources[s.flag] = l.source
+			break
+		}
+	}
+	return nil
+}
+
+// PrintConfig logs the resolved settings, with secrets redacted, for -show-config.
+func (o *Options) PrintConfig() error {
+	if err := o.resolve(); err != nil {
+		return err
+	}
+	l := o.logger()
+	l.Printf("%-20s %-40s %s", "context", o.Context, o.sources["context"])
+	for _, s := range settings {
+		v := s.get(o)
+		switch {
+		case s.flag == "s":
+			v = RedactURLs(v)
+		case s.secret && v != "":
+			v = redacted
+		}
+		l.Printf("%-20s %-40s %s", s.flag, v, o.sources[s.flag])
+	}
+	return nil
+}
+
+// RedactURLs hides any password or token embedded in the server URLs.
+func RedactURLs(urls string) string {
+	list := strings.Split(urls, ",")
+	for i, s := range list {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil || u.User == nil {
+			continue
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		} else {
+			u.User = url.User(redacted)
+		}
+		list[i] = strings.Replace(u.String(), url.QueryEscape(redacted), redacted, -1)
+	}
+	return strings.Join(list, ",")
+}
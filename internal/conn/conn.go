@@ -0,0 +1,208 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conn builds the NATS connection options shared by every command
+// from one common set of command line flags.
+package conn
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/jsonl"
+)
+
+// Usage is the synopsis of the connection flags, for use in command usage lines.
+const Usage = "[-context name] [-s server] [-creds file] [-nkey file] [-jwt jwt -seed seed] [-user user [-password password]] [-token token] [-tls] [-tlscert file] [-tlskey file] [-tlscacert file] [-domain jsdomain] [-reconnect-wait duration] [-reconnect-jitter duration] [-reconnect-jitter-tls duration] [-max-reconnects n] [-reconnect-buf-size bytes] [-retry-connect] [-drain-timeout duration] [-events file] [-show-config]"
+
+// Options holds the connection settings common to every command.
+type Options struct {
+	// Name is the connection name reported to the server.
+	Name string
+
+	URLs          string
+	UserCreds     string
+	NkeyFile      string
+	JWT           string
+	Seed          string
+	User          string
+	Password      string
+	Token         string
+	TLS           bool
+	TLSClientCert string
+	TLSClientKey  string
+	TLSCACert     string
+	JSDomain      string
+
+	// Reconnect policy.
+	ReconnectWait      time.Duration
+	ReconnectJitter    time.Duration
+	ReconnectJitterTLS time.Duration
+	MaxReconnects      int
+	ReconnectBufSize   int
+	RetryConnect       bool
+
+	// DrainTimeout bounds how long draining may take on shutdown.
+	DrainTimeout time.Duration
+
+	// EventsFile, if set, receives connection lifecycle events as JSON
+	// lines, "-" being stdout.
+	EventsFile string
+
+	// Context names the stored context supplying any setting not given as
+	// a flag or environment variable. When empty the selected context, if
+	// any, is used.
+	Context string
+
+	// ShowConfig requests the resolved settings be shown instead of connecting.
+	ShowConfig bool
+
+	// Log receives the connection log messages, the standard logger if nil.
+	Log *log.Logger
+
+	fs       *flag.FlagSet
+	resolved bool
+	sources  map[string]string
+	events   *jsonl.Writer
+}
+
+// NewOptions returns Options for a client connection called name.
+func NewOptions(name string) *Options {
+	return &Options{Name: name}
+}
+
+func (o *Options) logger() *log.Logger {
+	if o.Log == nil {
+		return log.Default()
+	}
+	return o.Log
+}
+
+// AddFlags registers the connection flags on fs.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	o.fs = fs
+	fs.StringVar(&o.Context, "context", "", "Named connection context to load settings from")
+	fs.StringVar(&o.URLs, "s", nats.DefaultURL, "The nats server URLs (separated by comma), nats://, tls://, ws:// or wss://")
+	fs.StringVar(&o.UserCreds, "creds", "", "User Credentials File")
+	fs.StringVar(&o.NkeyFile, "nkey", "", "NKey Seed File")
+	fs.StringVar(&o.JWT, "jwt", "", "User JWT, used with -seed")
+	fs.StringVar(&o.Seed, "seed", "", "User NKey seed, used with -jwt")
+	fs.StringVar(&o.User, "user", "", "Username")
+	fs.StringVar(&o.Password, "password", "", "Password")
+	fs.StringVar(&o.Token, "token", "", "Authentication token")
+	fs.BoolVar(&o.TLS, "tls", false, "Use TLS Secure Connection")
+	fs.StringVar(&o.TLSClientCert, "tlscert", "", "TLS client certificate file")
+	fs.StringVar(&o.TLSClientKey, "tlskey", "", "Private key file for client certificate")
+	fs.StringVar(&o.TLSCACert, "tlscacert", "", "CA certificate to verify peer against")
+	fs.StringVar(&o.JSDomain, "domain", "", "JetStream domain")
+	fs.DurationVar(&o.ReconnectWait, "reconnect-wait", time.Second, "Wait between reconnect attempts")
+	fs.DurationVar(&o.ReconnectJitter, "reconnect-jitter", nats.DefaultReconnectJitter, "Maximum random jitter added to -reconnect-wait")
+	fs.DurationVar(&o.ReconnectJitterTLS, "reconnect-jitter-tls", nats.DefaultReconnectJitterTLS, "Maximum random jitter added to -reconnect-wait for TLS connections")
+	fs.IntVar(&o.MaxReconnects, "max-reconnects", 600, "Maximum reconnect attempts, -1 for no limit")
+	fs.IntVar(&o.ReconnectBufSize, "reconnect-buf-size", nats.DefaultReconnectBufSize, "Bytes of outgoing messages buffered while reconnecting")
+	fs.BoolVar(&o.RetryConnect, "retry-connect", false, "Retry the initial connection as for a reconnect")
+	fs.DurationVar(&o.DrainTimeout, "drain-timeout", nats.DefaultDrainTimeout, "Maximum time to drain subscriptions on shutdown")
+	fs.StringVar(&o.EventsFile, "events", "", "Append connection lifecycle events as JSON lines to file (- for stdout)")
+	fs.BoolVar(&o.ShowConfig, "show-config", false, "Show the resolved connection settings and exit")
+}
+
+// NatsOptions returns the nats.Options described by o, for one connection:
+// its event handlers keep the state of the connection.
+func (o *Options) NatsOptions() ([]nats.Option, error) {
+	if err := o.resolve(); err != nil {
+		return nil, err
+	}
+
+	if o.EventsFile != "" && o.events == nil {
+		w, err := jsonl.Create(o.EventsFile)
+		if err != nil {
+			return nil, err
+		}
+		o.events = w
+	}
+
+	opts := []nats.Option{nats.Name(o.Name)}
+	opts = append(opts, nats.ReconnectWait(o.ReconnectWait))
+	opts = append(opts, nats.ReconnectJitter(o.ReconnectJitter, o.ReconnectJitterTLS))
+	opts = append(opts, nats.MaxReconnects(o.MaxReconnects))
+	opts = append(opts, nats.ReconnectBufSize(o.ReconnectBufSize))
+	opts = append(opts, nats.RetryOnFailedConnect(o.RetryConnect))
+	opts = append(opts, nats.DrainTimeout(o.DrainTimeout))
+	opts = append(opts, eventHandlers(o.events, o.MaxReconnects, o.logger())...)
+
+	authOpts, err := o.authOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, authOpts...)
+
+	// Use the path of websocket URLs, as for a proxy in front of the server.
+	wsPath, err := websocketPath(o.URLs)
+	if err != nil {
+		return nil, err
+	}
+	if wsPath != "" {
+		opts = append(opts, nats.ProxyPath(wsPath))
+	}
+
+	// Use TLS specified
+	if o.TLS {
+		opts = append(opts, nats.Secure(nil))
+	}
+
+	// Use TLS client authentication
+	if o.TLSClientCert != "" && o.TLSClientKey != "" {
+		opts = append(opts, nats.ClientCert(o.TLSClientCert, o.TLSClientKey))
+	}
+
+	// Use specific CA certificate
+	if o.TLSCACert != "" {
+		opts = append(opts, nats.RootCAs(o.TLSCACert))
+	}
+
+	return opts, nil
+}
+
+// Connect connects to NATS. Any extra options are applied after, and so
+// take precedence over, those built from o.
+func (o *Options) Connect(extra ...nats.Option) (*nats.Conn, error) {
+	opts, err := o.NatsOptions()
+	if err != nil {
+		return nil, err
+	}
+	return nats.Connect(o.URLs, append(opts, extra...)...)
+}
+
+// JetStream returns a JetStream context for nc in the configured domain.
+func (o *Options) JetStream(nc *nats.Conn, extra ...nats.JSOpt) (nats.JetStreamContext, error) {
+	if err := o.resolve(); err != nil {
+		return nil, err
+	}
+	var opts []nats.JSOpt
+	if o.JSDomain != "" {
+		opts = append(opts, nats.Domain(o.JSDomain))
+	}
+	return nc.JetStream(append(opts, extra...)...)
+}
+
+// APIError returns err from a JetStream API request, reporting no responders
+// as JetStream not being enabled: only the server answers the API.
+func APIError(err error) error {
+	if errors.Is(err, nats.ErrNoResponders) {
+		return nats.ErrJetStreamNotEnabled
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/jsonl"
+)
+
+// Connection lifecycle event types.
+const (
+	EventConnected         = "connected"
+	EventDisconnected      = "disconnected"
+	EventReconnected       = "reconnected"
+	EventClosed            = "closed"
+	EventDiscoveredServers = "discovered_servers"
+	EventLameDuck          = "lame_duck"
+)
+
+// Event is a connection lifecycle event, written as one JSON line to the
+// -events file.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Name     string    `json:"name"`
+	URL      string    `json:"url,omitempty"`
+	ServerID string    `json:"server_id,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Servers  []string  `json:"servers,omitempty"`
+}
+
+// Drain drains nc and waits for it to be closed. It returns
+// nats.ErrDrainTimeout if the subscriptions did not drain within the drain
+// timeout.
+func Drain(nc *nats.Conn) error {
+	closed := make(chan struct{})
+	handler := nc.ClosedHandler()
+	nc.SetClosedHandler(func(nc *nats.Conn) {
+		if handler != nil {
+			handler(nc)
+		}
+		close(closed)
+	})
+	if err := nc.Drain(); err != nil {
+		return err
+	}
+	<-closed
+	if err := nc.LastError(); errors.Is(err, nats.ErrDrainTimeout) {
+		return err
+	}
+	return nil
+}
+
+// eventHandlers returns the connection handlers, which log each event to l
+// and, if w is not nil, write it to w. The handlers are for one connection.
+func eventHandlers(w *jsonl.Writer, maxReconnects int, l *log.Logger) []nats.Option {
+	// connected records whether the connection has connected at least once.
+	var connected atomic.Bool
+	emit := func(nc *nats.Conn, typ string, err error) {
+		if w == nil {
+			return
+		}
+		e := Event{Time: time.Now().UTC(), Type: typ, Name: nc.Opts.Name}
+		if typ != EventClosed && typ != EventDisconnected {
+			e.URL = RedactURLs(nc.ConnectedUrl())
+			e.ServerID = nc.ConnectedServerId()
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		if typ == EventDiscoveredServers {
+			e.Servers = nc.DiscoveredServers()
+		}
+		if err := w.Write(e); err != nil {
+			l.Printf("Writing event: %v", err)
+		}
+	}
+
+	return []nats.Option{
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			connected.Store(true)
+			emit(nc, EventConnected, nil)
+		}),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			if err != nil {
+				if maxReconnects < 0 {
+					l.Printf("Disconnected due to: %s, will attempt reconnects", err)
+				} else {
+					l.Printf("Disconnected due to: %s, will attempt %d reconnects", err, maxReconnects)
+				}
+			}
+			emit(nc, EventDisconnected, err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			// A connection retrying its initial connect is reported as reconnected.
+			if !connected.Swap(true) {
+				l.Printf("Connected [%s]", RedactURLs(nc.ConnectedUrl()))
+				emit(nc, EventConnected, nil)
+				return
+			}
+			l.Printf("Reconnected [%s]", RedactURLs(nc.ConnectedUrl()))
+			emit(nc, EventReconnected, nil)
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			if nc.LastError() != nil {
+				l.Printf("ClosedHandler: %v", nc.LastError())
+			}
+			emit(nc, EventClosed, nc.LastError())
+		}),
+		nats.DiscoveredServersHandler(func(nc *nats.Conn) {
+			emit(nc, EventDiscoveredServers, nil)
+		}),
+		nats.ErrorHandler(func(nc *nats.Conn, sub *nats.Subscription, err error) {
+			if sub != nil {
+				l.Printf("Error on [%s]: %v", sub.Subject, err)
+				return
+			}
+			l.Printf("Error: %v", err)
+		}),
+		nats.LameDuckModeHandler(func(nc *nats.Conn) {
+			l.Printf("Server [%s] entered lame duck mode", RedactURLs(nc.ConnectedUrl()))
+			emit(nc, EventLameDuck, nil)
+		}),
+	}
+}
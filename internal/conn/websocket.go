@@ -0,0 +1,49 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conn
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// websocketPath checks that the server URLs are either all websocket
+// (ws://, wss://) or all not, and returns the path of the websocket URLs,
+// which is used as the proxy path of the connection.
+func websocketPath(urls string) (string, error) {
+	var ws, other bool
+	path := ""
+	for _, s := range strings.Split(urls, ",") {
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, "ws://") && !strings.HasPrefix(s, "wss://") {
+			other = true
+			continue
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", err
+		}
+		p := strings.TrimSuffix(u.Path, "/")
+		if ws && p != path {
+			return "", status.Invalidf("websocket URLs must share one path, got %q and %q", path, p)
+		}
+		ws, path = true, p
+	}
+	if ws && other {
+		return "", status.Invalidf("specify only websocket or only non websocket URLs in -s")
+	}
+	return path, nil
+}
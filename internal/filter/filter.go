@@ -0,0 +1,175 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filter selects the messages received by the subscribing commands
+// with -filter expressions on their subject, headers and body, such as
+// `header.Tenant == "acme" && .amount > 100`. The expression language is
+// documented in the README.
+package filter
+
+import (
+	"encoding/json"
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Options are the filter flags.
+type Options struct {
+	// Exprs are the -filter expressions, all of which a message must match.
+	Exprs []string
+}
+
+// AddFlags registers the -filter flag on fs.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.Var((*exprs)(&o.Exprs), "filter", "Only handle messages matching this expression, such as '.status == \"failed\"', may be repeated")
+}
+
+// Filter matches messages against the -filter expressions.
+type Filter struct {
+	nodes []node
+}
+
+// Filter returns the Filter of the expressions given, nil if none.
+func (o *Options) Filter() (*Filter, error) {
+	if len(o.Exprs) == 0 {
+		return nil, nil
+	}
+	f := &Filter{}
+	for _, expr := range o.Exprs {
+		n, err := parse(expr)
+		if err != nil {
+			return nil, status.Invalidf("-filter %q: %v", expr, err)
+		}
+		f.nodes = append(f.nodes, n)
+	}
+	return f, nil
+}
+
+// Match reports whether m matches every expression. A nil Filter matches
+// every message.
+func (f *Filter) Match(m *nats.Msg) bool {
+	if f == nil {
+		return true
+	}
+	mv := &msg{m: m}
+	for _, n := range f.nodes {
+		if !truthy(n.eval(mv)) {
+			return false
+		}
+	}
+	return true
+}
+
+// msg is a message being matched, its body decoded from JSON once needed.
+type msg struct {
+	m       *nats.Msg
+	body    interface{}
+	decoded bool
+}
+
+func (mv *msg) json() interface{} {
+	if !mv.decoded {
+		mv.decoded = true
+		if err := json.Unmarshal(mv.m.Data, &mv.body); err != nil {
+			mv.body = nil
+		}
+	}
+	return mv.body
+}
+
+// truthy reports whether v counts as true on its own: set, and not false,
+// zero or empty.
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+// number returns v as a number, converting strings such as header values.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compare compares a to b, returning false if they are not comparable.
+func compare(a, b interface{}) (int, bool) {
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum {
+		x, ok1 := number(a)
+		y, ok2 := number(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	}
+	return 0, false
+}
+
+// equal reports whether a equals b, numbers compared by value.
+func equal(a, b interface{}) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		bb, ok := b.(bool)
+		return ok && a == bb
+	}
+	return false
+}
+
+type exprs []string
+
+func (e *exprs) String() string {
+	if e == nil {
+		return ""
+	}
+	return strings.Join(*e, ", ")
+}
+
+func (e *exprs) Set(s string) error {
+	*e = append(*e, s)
+	return nil
+}
@@ -0,0 +1,468 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The grammar of the expressions:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) operand | ( "=~" | "!~" ) string ]
+//	operand    = string | number | "true" | "false" | "null" | "subject" | "reply" | "body" | header | path
+//	header     = "header" ( "." name | "[" string "]" )
+//	path       = "." [ step ] { "." step | "[" ( string | number ) "]" }
+//	step       = name | digits
+//
+// A step of digits after "." is an array index, or a key of an object.
+
+// node is a parsed expression, evaluated for a message.
+type node interface {
+	eval(mv *msg) interface{}
+}
+
+type literal struct{ v interface{} }
+
+func (n literal) eval(*msg) interface{} { return n.v }
+
+type field string
+
+func (n field) eval(mv *msg) interface{} {
+	switch n {
+	case "subject":
+		return mv.m.Subject
+	case "reply":
+		return mv.m.Reply
+	}
+	return string(mv.m.Data)
+}
+
+type header string
+
+func (n header) eval(mv *msg) interface{} {
+	if vals, ok := mv.m.Header[string(n)]; ok && len(vals) > 0 {
+		return vals[0]
+	}
+	return nil
+}
+
+// path is a JSON path into the body, of object keys and array indexes, an
+// index also looking up its key in an object.
+type path []interface{}
+
+func (n path) eval(mv *msg) interface{} {
+	v := mv.json()
+	for _, step := range n {
+		switch step := step.(type) {
+		case string:
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = obj[step]
+		case int:
+			switch vv := v.(type) {
+			case []interface{}:
+				if step < 0 || step >= len(vv) {
+					return nil
+				}
+				v = vv[step]
+			case map[string]interface{}:
+				v = vv[strconv.Itoa(step)]
+			default:
+				return nil
+			}
+		}
+	}
+	return v
+}
+
+type not struct{ n node }
+
+func (n not) eval(mv *msg) interface{} { return !truthy(n.n.eval(mv)) }
+
+type logical struct {
+	and  bool
+	l, r node
+}
+
+func (n logical) eval(mv *msg) interface{} {
+	if n.and {
+		return truthy(n.l.eval(mv)) && truthy(n.r.eval(mv))
+	}
+	return truthy(n.l.eval(mv)) || truthy(n.r.eval(mv))
+}
+
+type comparison struct {
+	op   string
+	l, r node
+}
+
+func (n comparison) eval(mv *msg) interface{} {
+	l, r := n.l.eval(mv), n.r.eval(mv)
+	switch n.op {
+	case "==":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	}
+	c, ok := compare(l, r)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type match struct {
+	l      node
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (n match) eval(mv *msg) interface{} {
+	var s string
+	switch v := n.l.eval(mv).(type) {
+	case nil:
+		return n.negate
+	case string:
+		s = v
+	default:
+		s = fmt.Sprint(v)
+	}
+	return n.re.MatchString(s) != n.negate
+}
+
+// token kinds.
+const (
+	tokEOF = iota
+	tokString
+	tokNumber
+	tokName
+	tokPunct
+)
+
+type token struct {
+	kind int
+	text string
+}
+
+// lex splits expr into tokens.
+func lex(expr string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(expr) && expr[j] != c {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text := expr[i+1 : j]
+			if c == '"' {
+				s, err := strconv.Unquote(expr[i : j+1])
+				if err != nil {
+					return nil, fmt.Errorf("bad string at %d: %v", i, err)
+				}
+				text = s
+			}
+			toks = append(toks, token{tokString, text})
+			i = j + 1
+		case afterDot(toks) && isNameByte(c):
+			// A path step, such as the 0 of .items.0.name, is no number.
+			j := i
+			for j < len(expr) && isNameByte(expr[j]) {
+				j++
+			}
+			toks = append(toks, token{tokName, expr[i:j]})
+			i = j
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9':
+			j := i + 1
+			for j < len(expr) && strings.IndexByte("0123456789.eE+-", expr[j]) >= 0 {
+				j++
+			}
+			toks = append(toks, token{tokNumber, expr[i:j]})
+			i = j
+		case isNameByte(c):
+			j := i
+			for j < len(expr) && isNameByte(expr[j]) {
+				j++
+			}
+			toks = append(toks, token{tokName, expr[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, p := range []string{"==", "!=", "<=", ">=", "=~", "!~", "&&", "||", "<", ">", "!", "(", ")", "[", "]", "."} {
+				if strings.HasPrefix(expr[i:], p) {
+					op = p
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			toks = append(toks, token{tokPunct, op})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+// afterDot reports whether the last token of toks is ".".
+func afterDot(toks []token) bool {
+	return len(toks) > 0 && toks[len(toks)-1] == token{tokPunct, "."}
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c < unicode.MaxASCII && unicode.IsLetter(rune(c)) || c >= unicode.MaxASCII
+}
+
+// parser is a recursive descent parser of the tokens of an expression.
+type parser struct {
+	toks []token
+	pos  int
+}
+
+// parse parses expr.
+func parse(expr string) (node, error) {
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the punctuation s if next.
+func (p *parser) accept(s string) bool {
+	if t := p.peek(); t.kind == tokPunct && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return fmt.Errorf("expected %q", s)
+	}
+	return nil
+}
+
+func (p *parser) expr() (node, error) {
+	n, err := p.and()
+	for err == nil && p.accept("||") {
+		var r node
+		if r, err = p.and(); err == nil {
+			n = logical{l: n, r: r}
+		}
+	}
+	return n, err
+}
+
+func (p *parser) and() (node, error) {
+	n, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var r node
+		if r, err = p.unary(); err == nil {
+			n = logical{and: true, l: n, r: r}
+		}
+	}
+	return n, err
+}
+
+func (p *parser) unary() (node, error) {
+	switch {
+	case p.accept("!"):
+		n, err := p.unary()
+		return not{n}, err
+	case p.accept("("):
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokPunct {
+		return l, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return comparison{op: t.text, l: l, r: r}, nil
+	case "=~", "!~":
+		p.next()
+		rt := p.next()
+		if rt.kind != tokString {
+			return nil, fmt.Errorf("%s needs a regular expression string", t.text)
+		}
+		re, err := regexp.Compile(rt.text)
+		if err != nil {
+			return nil, err
+		}
+		return match{l: l, re: re, negate: t.text == "!~"}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) operand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", t.text)
+		}
+		return literal{f}, nil
+	case tokName:
+		switch t.text {
+		case "true", "false":
+			return literal{t.text == "true"}, nil
+		case "null":
+			return literal{nil}, nil
+		case "subject", "reply", "body":
+			return field(t.text), nil
+		case "header":
+			return p.header()
+		}
+		return nil, fmt.Errorf("unknown name %q, use subject, reply, body, header.Name or a .json.path", t.text)
+	case tokPunct:
+		if t.text == "." {
+			return p.path()
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+func (p *parser) header() (node, error) {
+	switch {
+	case p.accept("."):
+		t := p.next()
+		if t.kind != tokName {
+			return nil, fmt.Errorf("expected a header name after header.")
+		}
+		return header(t.text), nil
+	case p.accept("["):
+		t := p.next()
+		if t.kind != tokString {
+			return nil, fmt.Errorf("expected a header name string in header[]")
+		}
+		return header(t.text), p.expect("]")
+	}
+	return nil, fmt.Errorf("expected header.Name or header[\"Name\"]")
+}
+
+// path parses a JSON path, its leading "." consumed.
+func (p *parser) path() (node, error) {
+	var n path
+	if t := p.peek(); t.kind == tokName {
+		p.next()
+		n = append(n, step(t.text))
+	}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokName {
+				return nil, fmt.Errorf("expected a field name after .")
+			}
+			n = append(n, step(t.text))
+		case p.accept("["):
+			t := p.next()
+			switch t.kind {
+			case tokString:
+				n = append(n, t.text)
+			case tokNumber:
+				i, err := strconv.Atoi(t.text)
+				if err != nil {
+					return nil, fmt.Errorf("bad index %q", t.text)
+				}
+				n = append(n, i)
+			default:
+				return nil, fmt.Errorf("expected an index or key in []")
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// step returns a path step after ".", an index if all digits.
+func step(name string) interface{} {
+	if i, err := strconv.Atoi(name); err == nil && i >= 0 {
+		return i
+	}
+	return name
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestParse(t *testing.T) {
+	m := &nats.Msg{
+		Subject: "orders.new",
+		Reply:   "_INBOX.1",
+		Header:  nats.Header{"Tenant": []string{"acme"}, "X-Retry": []string{"3"}},
+		Data:    []byte(`{"status":"failed","amount":150.5,"delta":-2,"ok":false,"items":[{"sku":"a1","qty":2},{"sku":"b2"}],"odd key":"x","0":"zero","nested":{"1":"one"}}`),
+	}
+	for _, tc := range []struct {
+		expr string
+		want bool
+	}{
+		// Fields and headers.
+		{`subject == "orders.new"`, true},
+		{`reply == "_INBOX.1"`, true},
+		{`body =~ "failed"`, true},
+		{`header.Tenant == "acme"`, true},
+		{`header["Tenant"] == 'acme'`, true},
+		{`header.X-Retry >= 3`, true},
+		{`header.Missing == null`, true},
+		{`header.Missing`, false},
+		{`header.Missing !~ "x"`, true},
+
+		// Paths and array indexes.
+		{`.status == "failed"`, true},
+		{`.items[0].sku == "a1"`, true},
+		{`.items.0.sku == "a1"`, true},
+		{`.items.1.sku == "b2"`, true},
+		{`.items[1].qty == null`, true},
+		{`.items.2 == null`, true},
+		{`.items[-1] == null`, true},
+		{`.["odd key"] == "x"`, true},
+		{`.0 == "zero"`, true},
+		{`.nested.1 == "one"`, true},
+		{`.nested[1] == "one"`, true},
+		{`.status.0 == null`, true},
+
+		// Numbers and comparisons.
+		{`.amount > 100`, true},
+		{`.amount > 150.5`, false},
+		{`.amount >= 150.5`, true},
+		{`.amount < 1.5e2`, false},
+		{`.delta == -2`, true},
+		{`.delta < -1.5`, true},
+		{`.status < "g"`, true},
+		{`.status > 1`, false},
+		{`.ok == false`, true},
+		{`.ok`, false},
+		{`.amount != 150.5`, false},
+
+		// Precedence, negation and parentheses.
+		{`true || false && false`, true},
+		{`(true || false) && false`, false},
+		{`!false && true`, true},
+		{`!(true && false)`, true},
+		{`!!.status`, true},
+		{`! .ok`, true},
+		{`false || !(subject == "orders.old" || .amount < 0)`, true},
+
+		// Strings and regular expressions.
+		{`"a\"b" == 'a"b'`, true},
+		{`"tab\there" =~ "\t"`, true},
+		{`'a\nb' == "a\\nb"`, true},
+		{`subject =~ "^orders\\."`, true},
+		{`subject !~ "^orders\\."`, false},
+		{`.amount =~ "^150"`, true},
+	} {
+		n, err := parse(tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		if got := truthy(n.eval(&msg{m: m})); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		err  string
+	}{
+		{``, "unexpected end"},
+		{`subject ==`, "unexpected end"},
+		{`(true`, `expected ")"`},
+		{`true)`, `unexpected ")"`},
+		{`"open`, "unterminated string"},
+		{`"bad\q"`, "bad string"},
+		{`subject =~ "("`, "missing closing )"},
+		{`subject =~ subject`, "needs a regular expression string"},
+		{`status == 1`, `unknown name "status"`},
+		{`header`, "expected header.Name"},
+		{`header[1]`, "expected a header name string"},
+		{`.items[x]`, "expected an index or key"},
+		{`.items[0`, `expected "]"`},
+		{`.items.`, "expected a field name"},
+		{`.a == 1.2.3`, "bad number"},
+		{`subject = "a"`, "unexpected '='"},
+	} {
+		_, err := parse(tc.expr)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want %q", tc.expr, err, tc.err)
+		}
+	}
+}
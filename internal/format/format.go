@@ -0,0 +1,122 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package format writes the messages received in the format chosen with
+// -format: raw payloads, JSON objects, hex dumps or a Go template.
+package format
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/jsonl"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Options are the format flags.
+type Options struct {
+	// Format is "text", "raw", "json", "hex" or a template.
+	Format string
+}
+
+// AddFlags registers the -format flag on fs.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Format, "format", "text", "Print messages as text, raw, json, hex or a Go template such as '{{.Subject}} {{.Data}}'")
+}
+
+// Formatter writes the messages received to stdout.
+type Formatter struct {
+	format string
+	t      *template.Template
+	w      io.Writer
+	jw     *jsonl.Writer
+}
+
+// Formatter returns the Formatter writing messages to w.
+func (o *Options) Formatter(w io.Writer) (*Formatter, error) {
+	f := &Formatter{format: o.Format, w: w}
+	switch o.Format {
+	case "text", "raw", "hex":
+	case "json":
+		f.jw = jsonl.NewWriter(w)
+	default:
+		if !strings.Contains(o.Format, "{{") {
+			return nil, status.Invalidf("invalid -format %q, use text, raw, json, hex or a template", o.Format)
+		}
+		t, err := template.New("format").Parse(o.Format)
+		if err != nil {
+			return nil, status.Invalidf("-format: %v", err)
+		}
+		f.format, f.t = "template", t
+	}
+	return f, nil
+}
+
+// Text reports whether messages are logged as text, the default, rather
+// than written by the Formatter.
+func (f *Formatter) Text() bool {
+	return f.format == "text"
+}
+
+// Msg is a message received, as given to -format templates.
+type Msg struct {
+	Seq     int
+	Subject string
+	Reply   string
+	Header  nats.Header
+	Data    string
+	Size    int
+	Time    time.Time
+}
+
+// Write writes m, the seq'th message received.
+func (f *Formatter) Write(m *nats.Msg, seq int) error {
+	switch f.format {
+	case "raw":
+		_, err := f.w.Write(m.Data)
+		return err
+	case "json":
+		return f.jw.Write(output.NewMessage(m, seq))
+	case "hex":
+		_, err := fmt.Fprintf(f.w, "[#%d] Received on [%s]: %d bytes\n%s", seq, m.Subject, len(m.Data), hex.Dump(m.Data))
+		return err
+	case "template":
+		var b bytes.Buffer
+		err := f.t.Execute(&b, &Msg{
+			Seq:     seq,
+			Subject: m.Subject,
+			Reply:   m.Reply,
+			Header:  m.Header,
+			Data:    string(m.Data),
+			Size:    len(m.Data),
+			Time:    time.Now(),
+		})
+		if err != nil {
+			return status.Invalidf("-format: %v", err)
+		}
+		if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+			b.WriteByte('\n')
+		}
+		_, err = f.w.Write(b.Bytes())
+		return err
+	}
+	return nil
+}
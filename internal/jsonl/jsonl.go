@@ -0,0 +1,64 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonl writes values as JSON lines, one JSON object per line.
+package jsonl
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Writer writes values as JSON lines. It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{enc: json.NewEncoder(w)}
+}
+
+// Create returns a Writer appending to the file at path, or writing to
+// stdout if path is "-".
+func Create(path string) (*Writer, error) {
+	if path == "-" {
+		return NewWriter(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := NewWriter(f)
+	w.c = f
+	return w, nil
+}
+
+// Write writes v as one line.
+func (w *Writer) Write(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(v)
+}
+
+// Close closes the underlying file, if Create opened one.
+func (w *Writer) Close() error {
+	if w.c == nil {
+		return nil
+	}
+	return w.c.Close()
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exposes the metrics of the long-running commands in the
+// Prometheus text format, served over HTTP with the -metrics-addr flag.
+package metrics
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Path is the HTTP path the metrics are served on.
+const Path = "/metrics"
+
+// latencyBuckets are the upper bounds, in seconds, of the handler latency
+// histogram buckets.
+var latencyBuckets = []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5}
+
+// Metrics are the metrics of a command handling messages.
+type Metrics struct {
+	// Addr is the address to serve the metrics on, not served if empty.
+	Addr string
+
+	// MsgsIn and BytesIn count the messages received.
+	MsgsIn, BytesIn *Counter
+	// MsgsOut and BytesOut count the messages sent, such as replies.
+	MsgsOut, BytesOut *Counter
+	// Acks and Naks count the JetStream acknowledgements sent.
+	Acks, Naks *Counter
+	// Latency is the time taken handling each message.
+	Latency *Histogram
+
+	reg registry
+
+	mu   sync.Mutex
+	nc   *nats.Conn
+	subs []*nats.Subscription
+	// dropped are the messages last seen dropped by each subscription.
+	dropped []int
+}
+
+// New returns the metrics, all zero.
+func New() *Metrics {
+	m := &Metrics{}
+	r := &m.reg
+	m.MsgsIn = r.counter("gonats_messages_received_total", "Messages received.")
+	m.BytesIn = r.counter("gonats_bytes_received_total", "Payload bytes received.")
+	m.MsgsOut = r.counter("gonats_messages_sent_total", "Messages sent.")
+	m.BytesOut = r.counter("gonats_bytes_sent_total", "Payload bytes sent.")
+	m.Acks = r.counter("gonats_acks_total", "JetStream messages acknowledged.")
+	m.Naks = r.counter("gonats_naks_total", "JetStream messages negatively acknowledged.")
+	m.Latency = r.histogram("gonats_handler_duration_seconds", "Time taken handling a message.", latencyBuckets)
+	r.counterFunc("gonats_reconnects_total", "Reconnections to the server.", func() float64 {
+		if nc := m.conn(); nc != nil {
+			return float64(nc.Stats().Reconnects)
+		}
+		return 0
+	})
+	r.gaugeFunc("gonats_pending_messages", "Messages received but not yet handled.", func() float64 {
+		msgs, _ := m.pending()
+		return float64(msgs)
+	})
+	r.gaugeFunc("gonats_pending_bytes", "Payload bytes received but not yet handled.", func() float64 {
+		_, bytes := m.pending()
+		return float64(bytes)
+	})
+	r.counterFunc("gonats_slow_consumer_dropped_total", "Messages dropped as the subscription was a slow consumer.", func() float64 {
+		return float64(m.droppedTotal())
+	})
+	return m
+}
+
+// AddFlags registers the -metrics-addr flag on fs.
+func (m *Metrics) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&m.Addr, "metrics-addr", "", "Serve Prometheus metrics over HTTP on this address, such as :9090")
+}
+
+// Listen serves the metrics on Addr until the returned listener is closed.
+func (m *Metrics) Listen() (net.Listener, error) {
+	l, err := net.Listen("tcp", m.Addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Path, m)
+	go http.Serve(l, mux)
+	return l, nil
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.reg.writeTo(w)
+}
+
+// WatchConn reports the reconnections of nc.
+func (m *Metrics) WatchConn(nc *nats.Conn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nc = nc
+}
+
+// WatchSub reports the pending and dropped messages of sub, summed with
+// those of any other subscription watched.
+func (m *Metrics) WatchSub(sub *nats.Subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subs = append(m.subs, sub)
+	m.dropped = append(m.dropped, 0)
+}
+
+// Received records the receipt of msg.
+func (m *Metrics) Received(msg *nats.Msg) {
+	m.MsgsIn.Inc()
+	m.BytesIn.Add(len(msg.Data))
+}
+
+// Sent records sending a message with payload data.
+func (m *Metrics) Sent(data []byte) {
+	m.MsgsOut.Inc()
+	m.BytesOut.Add(len(data))
+}
+
+// Handled records the time taken handling a message since start.
+func (m *Metrics) Handled(start time.Time) {
+	m.Latency.Observe(time.Since(start))
+}
+
+func (m *Metrics) conn() *nats.Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nc
+}
+
+func (m *Metrics) subscriptions() []*nats.Subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.subs
+}
+
+// droppedTotal returns the messages dropped by the subscriptions watched,
+// those since closed counting as last seen, so that the total never goes
+// down.
+func (m *Metrics) droppedTotal() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := 0
+	for i, sub := range m.subs {
+		if n, err := sub.Dropped(); err == nil && n > m.dropped[i] {
+			m.dropped[i] = n
+		}
+		total += m.dropped[i]
+	}
+	return total
+}
+
+func (m *Metrics) pending() (int, int) {
+	msgs, bytes := 0, 0
+	for _, sub := range m.subscriptions() {
+		if n, b, err := sub.Pending(); err == nil {
+			msgs, bytes = msgs+n, bytes+b
+		}
+	}
+	return msgs, bytes
+}
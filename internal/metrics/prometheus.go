@@ -0,0 +1,144 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metric is one metric family in the Prometheus text exposition format.
+type metric interface {
+	write(w io.Writer)
+}
+
+// registry holds metrics in the order they are written.
+type registry struct {
+	metrics []metric
+}
+
+// writeTo writes every metric in the Prometheus text exposition format.
+func (r *registry) writeTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range r.metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	name, help string
+	n          uint64
+}
+
+func (r *registry) counter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.metrics = append(r.metrics, c)
+	return c
+}
+
+// Add adds n to the counter.
+func (c *Counter) Add(n int) {
+	atomic.AddUint64(&c.n, uint64(n))
+}
+
+// Inc increments the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, atomic.LoadUint64(&c.n))
+}
+
+// funcMetric is a counter or gauge whose value is read when written.
+type funcMetric struct {
+	name, help, typ string
+	value           func() float64
+}
+
+func (r *registry) counterFunc(name, help string, value func() float64) {
+	r.metrics = append(r.metrics, &funcMetric{name, help, "counter", value})
+}
+
+func (r *registry) gaugeFunc(name, help string, value func() float64) {
+	r.metrics = append(r.metrics, &funcMetric{name, help, "gauge", value})
+}
+
+func (m *funcMetric) write(w io.Writer) {
+	writeHeader(w, m.name, m.help, m.typ)
+	fmt.Fprintf(w, "%s %s\n", m.name, formatFloat(m.value()))
+}
+
+// Histogram counts observed durations in buckets.
+type Histogram struct {
+	name, help string
+	bounds     []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *registry) histogram(name, help string, bounds []float64) *Histogram {
+	h := &Histogram{name: name, help: help, bounds: bounds, counts: make([]uint64, len(bounds))}
+	r.metrics = append(r.metrics, h)
+	return h
+}
+
+// Observe records the duration d.
+func (h *Histogram) Observe(d time.Duration) {
+	v := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(b), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
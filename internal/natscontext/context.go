@@ -0,0 +1,203 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package natscontext stores named connection contexts on disk.
+//
+// Each context is a JSON file <config>/gonats/context/<name>.json, where
+// <config> is the user's configuration directory (see os.UserConfigDir).
+// The name of the selected (default) context is kept in
+// <config>/gonats/context.txt.
+package natscontext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Context is a named set of connection settings.
+type Context struct {
+	Name        string `json:"-"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Creds       string `json:"creds,omitempty"`
+	Nkey        string `json:"nkey,omitempty"`
+	JWT         string `json:"jwt,omitempty"`
+	Seed        string `json:"seed,omitempty"`
+	User        string `json:"user,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Token       string `json:"token,omitempty"`
+	TLSCert     string `json:"tls_cert,omitempty"`
+	TLSKey      string `json:"tls_key,omitempty"`
+	TLSCA       string `json:"tls_ca,omitempty"`
+	JSDomain    string `json:"jetstream_domain,omitempty"`
+}
+
+// ErrNotFound is returned when a context does not exist.
+var ErrNotFound = fmt.Errorf("context %w", status.ErrNotFound)
+
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gonats"), nil
+}
+
+func contextDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "context"), nil
+}
+
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return status.Invalidf("invalid context name %q", name)
+	}
+	return nil
+}
+
+func contextFile(name string) (string, error) {
+	if err := validName(name); err != nil {
+		return "", err
+	}
+	dir, err := contextDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// Load reads the context called name.
+func Load(name string) (*Context, error) {
+	path, err := contextFile(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Context{Name: name}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("context %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// Save writes c, replacing any existing context of the same name.
+func (c *Context) Save() error {
+	path, err := contextFile(c.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Delete removes the context called name, unselecting it if needed.
+func Delete(name string) error {
+	path, err := contextFile(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return err
+	}
+	if sel, _ := Selected(); sel == name {
+		return Select("")
+	}
+	return nil
+}
+
+// List returns the names of all known contexts, sorted.
+func List() ([]string, error) {
+	dir, err := contextDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Selected returns the name of the selected context, or "" if none is,
+// including when there is no configuration directory, as in a container
+// without $HOME.
+func Selected() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "context.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Select makes name the selected context. An empty name clears the selection.
+func Select(name string) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "context.txt")
+	if name == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if _, err := Load(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(name+"\n"), 0600)
+}
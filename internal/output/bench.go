@@ -0,0 +1,83 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"github.com/nats-io/nats.go/bench"
+)
+
+// Bench is a benchmark result, of type "bench".
+type Bench struct {
+	Event
+	Name    string      `json:"name"`
+	RunID   string      `json:"run_id"`
+	MsgSize int         `json:"msg_size"`
+	Total   *BenchStats `json:"total,omitempty"`
+	Pub     *BenchStats `json:"pub,omitempty"`
+	Sub     *BenchStats `json:"sub,omitempty"`
+}
+
+// BenchStats are the statistics of one side of a benchmark.
+type BenchStats struct {
+	Clients     int     `json:"clients"`
+	Msgs        uint64  `json:"msgs"`
+	Bytes       uint64  `json:"bytes"`
+	DurationSec float64 `json:"duration_sec"`
+	MsgsPerSec  int64   `json:"msgs_per_sec"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+
+	// Per client rates, when there is more than one client.
+	MinRate int64   `json:"min_rate,omitempty"`
+	AvgRate int64   `json:"avg_rate,omitempty"`
+	MaxRate int64   `json:"max_rate,omitempty"`
+	StdDev  float64 `json:"stddev,omitempty"`
+}
+
+func newBenchStats(s *bench.Sample, clients int) *BenchStats {
+	return &BenchStats{
+		Clients:     clients,
+		Msgs:        s.MsgCnt,
+		Bytes:       s.MsgBytes,
+		DurationSec: s.Seconds(),
+		MsgsPerSec:  s.Rate(),
+		BytesPerSec: s.Throughput(),
+	}
+}
+
+func newBenchGroupStats(sg *bench.SampleGroup) *BenchStats {
+	if !sg.HasSamples() {
+		return nil
+	}
+	st := newBenchStats(&sg.Sample, len(sg.Samples))
+	if len(sg.Samples) > 1 {
+		st.MinRate, st.AvgRate, st.MaxRate, st.StdDev = sg.MinRate(), sg.AvgRate(), sg.MaxRate(), sg.StdDev()
+	}
+	return st
+}
+
+// NewBench returns the event for the closed benchmark bm of msgSize messages.
+func NewBench(bm *bench.Benchmark, msgSize int) *Bench {
+	e := &Bench{
+		Event:   newEvent("bench"),
+		Name:    bm.Name,
+		RunID:   bm.RunID,
+		MsgSize: msgSize,
+		Pub:     newBenchGroupStats(bm.Pubs),
+		Sub:     newBenchGroupStats(bm.Subs),
+	}
+	if e.Pub != nil && e.Sub != nil {
+		e.Total = newBenchStats(&bm.Sample, len(bm.Pubs.Samples)+len(bm.Subs.Samples))
+	}
+	return e
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Info describes the server a connection is connected to, of type "info".
+type Info struct {
+	Event
+	ServerID          string        `json:"server_id"`
+	ServerName        string        `json:"server_name"`
+	Version           string        `json:"version"`
+	Cluster           string        `json:"cluster,omitempty"`
+	URL               string        `json:"url"`
+	DiscoveredServers []string      `json:"discovered_servers,omitempty"`
+	MaxPayload        int64         `json:"max_payload"`
+	Headers           bool          `json:"headers"`
+	TLS               *TLSInfo      `json:"tls,omitempty"`
+	RTT               RTTStats      `json:"rtt"`
+	JetStream         JetStreamInfo `json:"jetstream"`
+}
+
+// TLSInfo is the state of a TLS connection.
+type TLSInfo struct {
+	Version          string        `json:"version"`
+	CipherSuite      string        `json:"cipher_suite"`
+	PeerCertificates []Certificate `json:"peer_certificates"`
+}
+
+// Certificate is a certificate presented by the server, leaf first.
+type Certificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+}
+
+// RTTStats summarizes the round trip times measured to the server.
+type RTTStats struct {
+	Samples int     `json:"samples"`
+	MinMS   float64 `json:"min_ms"`
+	AvgMS   float64 `json:"avg_ms"`
+	MaxMS   float64 `json:"max_ms"`
+}
+
+// JetStreamInfo tells whether JetStream is available to the account.
+type JetStreamInfo struct {
+	Enabled bool   `json:"enabled"`
+	Domain  string `json:"domain,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// NewInfo returns the event for the server nc is connected to, with the RTT
+// samples measured and the JetStream account info, nil if JetStream is not
+// enabled.
+func NewInfo(nc *nats.Conn, rtts []time.Duration, ai *nats.AccountInfo) *Info {
+	e := &Info{
+		Event:             newEvent("info"),
+		ServerID:          nc.ConnectedServerId(),
+		ServerName:        nc.ConnectedServerName(),
+		Version:           nc.ConnectedServerVersion(),
+		Cluster:           nc.ConnectedClusterName(),
+		URL:               nc.ConnectedUrlRedacted(),
+		DiscoveredServers: nc.DiscoveredServers(),
+		MaxPayload:        nc.MaxPayload(),
+		Headers:           nc.HeadersSupported(),
+		RTT:               newRTTStats(rtts),
+	}
+	if cs, err := nc.TLSConnectionState(); err == nil {
+		e.TLS = newTLSInfo(cs)
+	}
+	if ai != nil {
+		e.JetStream = JetStreamInfo{Enabled: true, Domain: ai.Domain}
+	}
+	return e
+}
+
+func newRTTStats(rtts []time.Duration) RTTStats {
+	st := RTTStats{Samples: len(rtts)}
+	if len(rtts) == 0 {
+		return st
+	}
+	var min, max, sum time.Duration
+	for i, d := range rtts {
+		if i == 0 || d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		sum += d
+	}
+	st.MinMS, st.MaxMS = ms(min), ms(max)
+	st.AvgMS = ms(sum / time.Duration(len(rtts)))
+	return st
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func newTLSInfo(cs tls.ConnectionState) *TLSInfo {
+	t := &TLSInfo{Version: tlsVersion(cs.Version), CipherSuite: tls.CipherSuiteName(cs.CipherSuite)}
+	for _, c := range cs.PeerCertificates {
+		t.PeerCertificates = append(t.PeerCertificates, Certificate{
+			Subject:   c.Subject.String(),
+			Issuer:    c.Issuer.String(),
+			NotBefore: c.NotBefore.UTC(),
+			NotAfter:  c.NotAfter.UTC(),
+			DNSNames:  c.DNSNames,
+		})
+	}
+	return t
+}
+
+// tlsVersion returns the name of TLS version v, such as "TLS 1.3".
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", v)
+}
@@ -0,0 +1,390 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package output defines the JSON objects written by the -json output mode
+// of every command, one object per line on stdout. The schema is documented
+// in docs/json-output.md; fields are only ever added to it.
+package output
+
+import (
+	"flag"
+	"io"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/jsonl"
+	"github.com/tbeets/gonats-101/internal/natscontext"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Printer writes events as JSON lines, normally to stdout, when JSON is set.
+type Printer struct {
+	// JSON selects JSON lines output instead of log lines.
+	JSON bool
+
+	w *jsonl.Writer
+}
+
+// NewPrinter returns a Printer for log line output, writing any JSON lines to w.
+func NewPrinter(w io.Writer) *Printer {
+	return &Printer{w: jsonl.NewWriter(w)}
+}
+
+// AddFlags registers the -json flag on fs.
+func (p *Printer) AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&p.JSON, "json", false, "Output one JSON object per event to stdout")
+}
+
+// Print writes v as one JSON line.
+func (p *Printer) Print(v interface{}) {
+	if err := p.w.Write(v); err != nil {
+		log.Printf("Writing output: %v", err)
+	}
+}
+
+// Event is the part common to every output object.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+}
+
+func newEvent(typ string) Event {
+	return Event{Type: typ, Time: time.Now().UTC()}
+}
+
+// Payload is a message payload, given as UTF-8 text when valid, and in
+// base64 otherwise.
+type Payload struct {
+	Size       int    `json:"size"`
+	Data       string `json:"data,omitempty"`
+	DataBase64 []byte `json:"data_base64,omitempty"`
+}
+
+func newPayload(data []byte) Payload {
+	p := Payload{Size: len(data)}
+	if utf8.Valid(data) {
+		p.Data = string(data)
+	} else {
+		p.DataBase64 = data
+	}
+	return p
+}
+
+// Message is a received message, of type "message".
+type Message struct {
+	Event
+	Seq     int         `json:"seq"`
+	Subject string      `json:"subject"`
+	Reply   string      `json:"reply,omitempty"`
+	Queue   string      `json:"queue,omitempty"`
+	Headers nats.Header `json:"headers,omitempty"`
+	Payload
+	JetStream *JetStreamMeta `json:"jetstream,omitempty"`
+}
+
+// JetStreamMeta is the JetStream metadata of a message delivered by a consumer.
+type JetStreamMeta struct {
+	Stream      string    `json:"stream"`
+	Consumer    string    `json:"consumer"`
+	StreamSeq   uint64    `json:"stream_seq"`
+	ConsumerSeq uint64    `json:"consumer_seq"`
+	Delivered   uint64    `json:"delivered"`
+	Pending     uint64    `json:"pending"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// NewMessage returns the event for m, the seq'th message received.
+func NewMessage(m *nats.Msg, seq int) *Message {
+	e := &Message{
+		Event:   newEvent("message"),
+		Seq:     seq,
+		Subject: m.Subject,
+		Reply:   m.Reply,
+		Headers: m.Header,
+		Payload: newPayload(m.Data),
+	}
+	if m.Sub != nil {
+		e.Queue = m.Sub.Queue
+	}
+	if md, err := m.Metadata(); err == nil {
+		e.Reply = ""
+		e.JetStream = &JetStreamMeta{
+			Stream:      md.Stream,
+			Consumer:    md.Consumer,
+			StreamSeq:   md.Sequence.Stream,
+			ConsumerSeq: md.Sequence.Consumer,
+			Delivered:   md.NumDelivered,
+			Pending:     md.NumPending,
+			Timestamp:   md.Timestamp.UTC(),
+		}
+	}
+	return e
+}
+
+// Subscribed reports a subscription is ready, of type "subscribed".
+type Subscribed struct {
+	Event
+	Subject  string `json:"subject,omitempty"`
+	Queue    string `json:"queue,omitempty"`
+	Stream   string `json:"stream,omitempty"`
+	Consumer string `json:"consumer,omitempty"`
+}
+
+// NewSubscribed returns the event for a subscription on subject in queue.
+func NewSubscribed(subject, queue string) *Subscribed {
+	return &Subscribed{Event: newEvent("subscribed"), Subject: subject, Queue: queue}
+}
+
+// NewConsumerSubscribed returns the event for a subscription bound to a
+// JetStream consumer.
+func NewConsumerSubscribed(stream, consumer string) *Subscribed {
+	return &Subscribed{Event: newEvent("subscribed"), Stream: stream, Consumer: consumer}
+}
+
+// Published is a core NATS publish, of type "published".
+type Published struct {
+	Event
+	Subject string      `json:"subject"`
+	Reply   string      `json:"reply,omitempty"`
+	Headers nats.Header `json:"headers,omitempty"`
+	Payload
+}
+
+// NewPublished returns the event for m having been published.
+func NewPublished(m *nats.Msg) *Published {
+	return &Published{
+		Event:   newEvent("published"),
+		Subject: m.Subject,
+		Reply:   m.Reply,
+		Headers: m.Header,
+		Payload: newPayload(m.Data),
+	}
+}
+
+// PublishSummary sums up the messages published by a repeated publish, of
+// type "publish_summary".
+type PublishSummary struct {
+	Event
+	Msgs        int            `json:"msgs"`
+	Bytes       int            `json:"bytes"`
+	DurationSec float64        `json:"duration_sec"`
+	MsgsPerSec  float64        `json:"msgs_per_sec"`
+	BytesPerSec float64        `json:"bytes_per_sec"`
+	Subjects    map[string]int `json:"subjects,omitempty"`
+}
+
+// NewPublishSummary returns the event for msgs messages of bytes bytes in
+// total published over d.
+func NewPublishSummary(msgs, bytes int, d time.Duration) *PublishSummary {
+	e := &PublishSummary{Event: newEvent("publish_summary"), Msgs: msgs, Bytes: bytes, DurationSec: d.Seconds()}
+	if d > 0 {
+		e.MsgsPerSec, e.BytesPerSec = float64(msgs)/d.Seconds(), float64(bytes)/d.Seconds()
+	}
+	return e
+}
+
+// ReceiveSummary sums up the messages received by a subscribing command
+// with an exit condition, of type "receive_summary".
+type ReceiveSummary struct {
+	Event
+	Msgs        int            `json:"msgs"`
+	Bytes       int            `json:"bytes"`
+	DurationSec float64        `json:"duration_sec"`
+	Subjects    map[string]int `json:"subjects,omitempty"`
+	Excluded    int            `json:"excluded,omitempty"`
+	Dropped     int            `json:"dropped,omitempty"`
+}
+
+// NewReceiveSummary returns the event for msgs messages of bytes bytes in
+// total received over d.
+func NewReceiveSummary(msgs, bytes int, d time.Duration) *ReceiveSummary {
+	return &ReceiveSummary{Event: newEvent("receive_summary"), Msgs: msgs, Bytes: bytes, DurationSec: d.Seconds()}
+}
+
+// PublishAck is a JetStream publish acknowledgement, of type "publish_ack".
+type PublishAck struct {
+	Event
+	Subject   string `json:"subject"`
+	Stream    string `json:"stream"`
+	Seq       uint64 `json:"seq"`
+	Domain    string `json:"domain,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Size      int    `json:"size"`
+}
+
+// NewPublishAck returns the event for pa acknowledging m.
+func NewPublishAck(m *nats.Msg, pa *nats.PubAck) *PublishAck {
+	return &PublishAck{
+		Event:     newEvent("publish_ack"),
+		Subject:   m.Subject,
+		Stream:    pa.Stream,
+		Seq:       pa.Sequence,
+		Domain:    pa.Domain,
+		Duplicate: pa.Duplicate,
+		Size:      len(m.Data),
+	}
+}
+
+// Reply is a reply received to a request, of type "reply".
+type Reply struct {
+	Event
+	Request string      `json:"request"`
+	Subject string      `json:"subject"`
+	RTT     float64     `json:"rtt_ms"`
+	Headers nats.Header `json:"headers,omitempty"`
+	Payload
+}
+
+// NewReply returns the event for reply m to a request sent on request,
+// received rtt after the request.
+func NewReply(request string, m *nats.Msg, rtt time.Duration) *Reply {
+	return &Reply{
+		Event:   newEvent("reply"),
+		Request: request,
+		Subject: m.Subject,
+		RTT:     float64(rtt) / float64(time.Millisecond),
+		Headers: m.Header,
+		Payload: newPayload(m.Data),
+	}
+}
+
+// Stream is the configuration and state of a stream, of type "stream".
+type Stream struct {
+	Event
+	Name    string            `json:"name"`
+	Created time.Time         `json:"created"`
+	Config  nats.StreamConfig `json:"config"`
+	State   nats.StreamState  `json:"state"`
+}
+
+// NewStream returns the event for the stream described by si.
+func NewStream(si *nats.StreamInfo) *Stream {
+	return &Stream{
+		Event:   newEvent("stream"),
+		Name:    si.Config.Name,
+		Created: si.Created.UTC(),
+		Config:  si.Config,
+		State:   si.State,
+	}
+}
+
+// Consumer is the configuration of a consumer, of type "consumer".
+type Consumer struct {
+	Event
+	Stream  string              `json:"stream"`
+	Name    string              `json:"name"`
+	Created time.Time           `json:"created"`
+	Config  nats.ConsumerConfig `json:"config"`
+}
+
+// NewConsumer returns the event for the consumer described by ci.
+func NewConsumer(ci *nats.ConsumerInfo) *Consumer {
+	return &Consumer{
+		Event:   newEvent("consumer"),
+		Stream:  ci.Stream,
+		Name:    ci.Name,
+		Created: ci.Created.UTC(),
+		Config:  ci.Config,
+	}
+}
+
+// Service is a micro service starting or stopping, of type "service".
+type Service struct {
+	Event
+	Status  string `json:"status"`
+	Name    string `json:"name"`
+	ID      string `json:"id"`
+	Version string `json:"version,omitempty"`
+}
+
+// NewService returns the event for service name with id entering status
+// "started" or "stopped".
+func NewService(status, name, id, version string) *Service {
+	return &Service{Event: newEvent("service"), Status: status, Name: name, ID: id, Version: version}
+}
+
+// ServiceError is an error handling a micro service request, of type
+// "service_error".
+type ServiceError struct {
+	Event
+	Name        string `json:"name"`
+	ID          string `json:"id"`
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+}
+
+// NewServiceError returns the event for an error in service name with id
+// handling a request on subject.
+func NewServiceError(name, id, subject, description string) *ServiceError {
+	return &ServiceError{Event: newEvent("service_error"), Name: name, ID: id, Subject: subject, Description: description}
+}
+
+// Context is a stored connection context, of type "context", with any
+// secrets redacted.
+type Context struct {
+	Event
+	Name     string `json:"name"`
+	Selected bool   `json:"selected"`
+
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Creds       string `json:"creds,omitempty"`
+	Nkey        string `json:"nkey,omitempty"`
+	JWT         string `json:"jwt,omitempty"`
+	Seed        string `json:"seed,omitempty"`
+	User        string `json:"user,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Token       string `json:"token,omitempty"`
+	TLSCert     string `json:"tls_cert,omitempty"`
+	TLSKey      string `json:"tls_key,omitempty"`
+	TLSCA       string `json:"tls_ca,omitempty"`
+	JSDomain    string `json:"jetstream_domain,omitempty"`
+}
+
+// NewContext returns the event for c, which must already be redacted.
+func NewContext(c *natscontext.Context, selected bool) *Context {
+	return &Context{
+		Event:       newEvent("context"),
+		Name:        c.Name,
+		Selected:    selected,
+		Description: c.Description,
+		URL:         c.URL,
+		Creds:       c.Creds,
+		Nkey:        c.Nkey,
+		JWT:         c.JWT,
+		Seed:        c.Seed,
+		User:        c.User,
+		Password:    c.Password,
+		Token:       c.Token,
+		TLSCert:     c.TLSCert,
+		TLSKey:      c.TLSKey,
+		TLSCA:       c.TLSCA,
+		JSDomain:    c.JSDomain,
+	}
+}
+
+// Error is the error a command failed with, of type "error", written last.
+type Error struct {
+	Event
+	Class  string `json:"class"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// NewError returns the event for err, classified as its exit status.
+func NewError(err error) *Error {
+	code := status.Of(err)
+	return &Error{Event: newEvent("error"), Class: status.Class(code), Status: code, Error: err.Error()}
+}
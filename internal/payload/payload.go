@@ -0,0 +1,159 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package payload reads the payloads of the messages sent: given as the
+// argument, as a template, or read from a file or stdin, whole or a line per
+// message, and optionally decoded from base64 or hex.
+package payload
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/status"
+	"github.com/tbeets/gonats-101/internal/tmpl"
+)
+
+// Options are the payload flags.
+type Options struct {
+	// Encoding is "base64" or "hex" to decode the payloads, or empty.
+	Encoding string
+	// Lines sends each line of a payload file or stdin as one message.
+	Lines bool
+}
+
+// AddFlags registers the payload flags on fs.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Encoding, "encoding", "", "Decode the payload from base64 or hex")
+	fs.BoolVar(&o.Lines, "lines", false, "Send each line of the payload file or stdin as one message")
+}
+
+// Source yields the payloads of the messages sent.
+type Source struct {
+	tmpl   *tmpl.Template
+	data   []byte
+	lines  *bufio.Reader
+	decode func([]byte) ([]byte, error)
+	close  func() error
+}
+
+// Open opens the payload given by arg: "@file" for the contents of file, "-"
+// for stdin, and otherwise arg itself, a template rendered for each message.
+func (o *Options) Open(arg string, stdin io.Reader) (*Source, error) {
+	s := &Source{close: func() error { return nil }}
+	switch o.Encoding {
+	case "":
+		s.decode = func(b []byte) ([]byte, error) { return b, nil }
+	case "base64":
+		s.decode = func(b []byte) ([]byte, error) { return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b))) }
+	case "hex":
+		s.decode = func(b []byte) ([]byte, error) { return hex.DecodeString(string(bytes.TrimSpace(b))) }
+	default:
+		return nil, status.Invalidf("invalid -encoding %q, use base64 or hex", o.Encoding)
+	}
+
+	var r io.Reader
+	switch {
+	case arg == "-":
+		r = stdin
+	case strings.HasPrefix(arg, "@"):
+		f, err := os.Open(arg[1:])
+		if err != nil {
+			return nil, err
+		}
+		r, s.close = f, f.Close
+	case o.Lines:
+		return nil, status.Invalidf("-lines needs the payload from @file or - for stdin")
+	default:
+		t, err := tmpl.Parse("payload", arg)
+		if err != nil {
+			return nil, err
+		}
+		s.tmpl = t
+		return s, nil
+	}
+
+	if o.Lines {
+		s.lines = bufio.NewReader(r)
+		return s, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	if s.data, err = s.decode(data); err != nil {
+		s.close()
+		return nil, status.Invalidf("decoding payload: %v", err)
+	}
+	return s, nil
+}
+
+// Next returns the payload of the count'th message sent, counting from 1. In
+// line mode it returns io.EOF once every line has been returned.
+func (s *Source) Next(count int) ([]byte, error) {
+	switch {
+	case s.lines != nil:
+		line, err := s.lines.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		data, err := s.decode(line)
+		if err != nil {
+			return nil, status.Invalidf("decoding line %d: %v", count, err)
+		}
+		return data, nil
+	case s.tmpl != nil:
+		text, err := s.tmpl.Render(count)
+		if err != nil {
+			return nil, err
+		}
+		data, err := s.decode([]byte(text))
+		if err != nil {
+			return nil, status.Invalidf("decoding payload: %v", err)
+		}
+		return data, nil
+	}
+	return s.data, nil
+}
+
+// Lines reports whether each line is a payload, so that the messages sent
+// are as many as the lines.
+func (s *Source) Lines() bool {
+	return s.lines != nil
+}
+
+// Close closes the payload file, if any.
+func (s *Source) Close() error {
+	return s.close()
+}
+
+// Check returns an error if data is larger than the max payload of the server
+// nc is connected to.
+func Check(nc *nats.Conn, data []byte) error {
+	if max := nc.MaxPayload(); int64(len(data)) > max {
+		return status.Invalidf("payload of %d bytes exceeds the server's max payload of %d bytes", len(data), max)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package receive ends the subscribing commands before they are interrupted:
+// once a number of messages are received, when none are in time, or once
+// idle.
+package receive
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Limits are the exit condition flags.
+type Limits struct {
+	// Count is the number of messages to receive, 0 for no limit.
+	Count int
+	// Timeout fails the command if the messages are not all received in
+	// time, or none without Count.
+	Timeout time.Duration
+	// Idle ends the command once no message is received for this long.
+	Idle time.Duration
+}
+
+// AddFlags registers the exit condition flags on fs.
+func (l *Limits) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&l.Count, "count", 0, "Exit after receiving this many messages, 0 for no limit")
+	fs.DurationVar(&l.Timeout, "timeout", 0, "Fail if -count messages, or any without -count, are not received in time")
+	fs.DurationVar(&l.Idle, "idle", 0, "Exit once no message is received for this long")
+}
+
+// Set reports whether any exit condition is set.
+func (l *Limits) Set() bool {
+	return l.Count > 0 || l.Timeout > 0 || l.Idle > 0
+}
+
+// Check returns an error if the limits are invalid.
+func (l *Limits) Check() error {
+	if l.Count < 0 || l.Timeout < 0 || l.Idle < 0 {
+		return status.Invalidf("-count, -timeout and -idle must not be negative")
+	}
+	return nil
+}
+
+// Counter counts the messages received by one or more subscriptions, in
+// total and per subscription, waking Wait.
+type Counter struct {
+	l        Limits
+	start    time.Time
+	mu       sync.Mutex
+	msgs     int
+	bytes    int
+	subjects map[string]int
+	excluded int
+	dropped  int
+	notify   chan struct{}
+}
+
+// Counter returns a Counter for the messages of subscriptions.
+func (l *Limits) Counter() *Counter {
+	return &Counter{l: *l, start: time.Now(), subjects: map[string]int{}, notify: make(chan struct{}, 1)}
+}
+
+// Add counts the messages of sub, unsubscribing it after Count messages
+// unless filtered, when not every message of sub is counted.
+func (c *Counter) Add(sub *nats.Subscription, filtered bool) error {
+	c.mu.Lock()
+	c.subjects[sub.Subject] += 0
+	c.mu.Unlock()
+	if c.l.Count == 0 || filtered {
+		return nil
+	}
+	return sub.AutoUnsubscribe(c.l.Count)
+}
+
+// Done reports whether Count messages have been received, so that any more
+// are to be ignored.
+func (c *Counter) Done() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.l.Count > 0 && c.msgs >= c.l.Count
+}
+
+// Excluded counts m as received but excluded.
+func (c *Counter) Excluded(m *nats.Msg) {
+	c.mu.Lock()
+	c.excluded++
+	c.mu.Unlock()
+}
+
+// Dropped counts m as received but not matching the -filter expressions.
+func (c *Counter) Dropped(m *nats.Msg) {
+	c.mu.Lock()
+	c.dropped++
+	c.mu.Unlock()
+}
+
+// Received counts m as received.
+func (c *Counter) Received(m *nats.Msg) {
+	c.mu.Lock()
+	c.msgs++
+	c.bytes += len(m.Data)
+	if m.Sub != nil {
+		c.subjects[m.Sub.Subject]++
+	}
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Wait waits until the command is interrupted or an exit condition is met,
+// returning an error with the Timeout status if the messages are not
+// received in time.
+func (c *Counter) Wait(e *cli.Env) error {
+	var timeout <-chan time.Time
+	if c.l.Timeout > 0 {
+		timeout = time.After(c.l.Timeout)
+	}
+	var idle *time.Timer
+	var idleC <-chan time.Time
+	if c.l.Idle > 0 {
+		idle = time.NewTimer(c.l.Idle)
+		defer idle.Stop()
+		idleC = idle.C
+	}
+	for {
+		select {
+		case <-e.Interrupted():
+			return nil
+		case <-idleC:
+			return nil
+		case <-timeout:
+			msgs, _ := c.counts()
+			if c.l.Count > 0 {
+				return fmt.Errorf("received %d of %d messages in %v: %w", msgs, c.l.Count, c.l.Timeout, nats.ErrTimeout)
+			} else if msgs == 0 {
+				return fmt.Errorf("no message received in %v: %w", c.l.Timeout, nats.ErrTimeout)
+			}
+			timeout = nil
+		case <-c.notify:
+			msgs, _ := c.counts()
+			if c.l.Count > 0 && msgs >= c.l.Count {
+				return nil
+			}
+			if idle != nil {
+				if !idle.Stop() {
+					<-idle.C
+				}
+				idle.Reset(c.l.Idle)
+			}
+		}
+	}
+}
+
+func (c *Counter) counts() (msgs, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.msgs, c.bytes
+}
+
+// Summarize reports the messages received so far, per subscription when
+// more than one, and those excluded or dropped.
+func (c *Counter) Summarize(e *cli.Env) {
+	c.mu.Lock()
+	sum := output.NewReceiveSummary(c.msgs, c.bytes, time.Since(c.start))
+	if len(c.subjects) > 1 {
+		sum.Subjects = map[string]int{}
+		for subj, n := range c.subjects {
+			sum.Subjects[subj] = n
+		}
+	}
+	sum.Excluded, sum.Dropped = c.excluded, c.dropped
+	c.mu.Unlock()
+
+	if e.Out.JSON {
+		e.Out.Print(sum)
+		return
+	}
+	e.Log.Printf("Received %d msgs, %d bytes in %v", sum.Msgs, sum.Bytes, time.Since(c.start).Round(time.Millisecond))
+	names := make([]string, 0, len(sum.Subjects))
+	for subj := range sum.Subjects {
+		names = append(names, subj)
+	}
+	sort.Strings(names)
+	for _, subj := range names {
+		e.Log.Printf("  [%s]: %d msgs", subj, sum.Subjects[subj])
+	}
+	if sum.Excluded > 0 {
+		e.Log.Printf("  excluded: %d msgs", sum.Excluded)
+	}
+	if sum.Dropped > 0 {
+		e.Log.Printf("  dropped by -filter: %d msgs", sum.Dropped)
+	}
+}
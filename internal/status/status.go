@@ -0,0 +1,122 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package status classifies the errors of the commands, giving their exit
+// status and the class reported by the -json error output.
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Exit statuses of the commands.
+const (
+	// OK is the status of a command succeeding, or shutting down cleanly
+	// once interrupted.
+	OK = 0
+	// Error is the status of a command failing otherwise.
+	Error = 1
+	// DrainTimeout is the status of a command whose subscriptions did not
+	// drain within the drain timeout on shutdown.
+	DrainTimeout = 2
+	// Invalid is the status of a command given invalid arguments or settings.
+	Invalid = 3
+	// Connection is the status of a command failing to connect.
+	Connection = 4
+	// Authorization is the status of a command denied by the server.
+	Authorization = 5
+	// Timeout is the status of a command timing out.
+	Timeout = 6
+	// NoResponders is the status of a request with no responders.
+	NoResponders = 7
+	// JetStreamNotEnabled is the status of a command using JetStream where
+	// it is not enabled.
+	JetStreamNotEnabled = 8
+	// NotFound is the status of a command naming a stream, consumer or
+	// context that does not exist.
+	NotFound = 9
+)
+
+// classes names the class of each status.
+var classes = map[int]string{
+	OK:                  "ok",
+	Error:               "error",
+	DrainTimeout:        "drain_timeout",
+	Invalid:             "invalid",
+	Connection:          "connection",
+	Authorization:       "authorization",
+	Timeout:             "timeout",
+	NoResponders:        "no_responders",
+	JetStreamNotEnabled: "jetstream_not_enabled",
+	NotFound:            "not_found",
+}
+
+// Class returns the name of the class of status, such as "timeout".
+func Class(status int) string {
+	return classes[status]
+}
+
+// ErrInvalid is matched by the errors for invalid arguments or settings.
+var ErrInvalid = errors.New("invalid")
+
+// ErrNotFound is wrapped by the errors for other missing things than streams
+// and consumers, such as contexts.
+var ErrNotFound = errors.New("not found")
+
+// invalidError is an error marked as a validation error.
+type invalidError struct {
+	err error
+}
+
+func (e *invalidError) Error() string        { return e.err.Error() }
+func (e *invalidError) Unwrap() error        { return e.err }
+func (e *invalidError) Is(target error) bool { return target == ErrInvalid }
+
+// Invalidf returns a validation error formatted as fmt.Errorf does.
+func Invalidf(format string, a ...interface{}) error {
+	return &invalidError{fmt.Errorf(format, a...)}
+}
+
+// Of returns the exit status for err, OK if err is nil.
+func Of(err error) int {
+	var opErr *net.OpError
+	switch {
+	case err == nil:
+		return OK
+	case errors.Is(err, nats.ErrDrainTimeout):
+		return DrainTimeout
+	case errors.Is(err, ErrInvalid), errors.Is(err, nats.ErrMaxPayload), errors.Is(err, nats.ErrBadSubject):
+		return Invalid
+	case errors.Is(err, nats.ErrAuthorization), errors.Is(err, nats.ErrAuthExpired),
+		errors.Is(err, nats.ErrAuthRevoked), errors.Is(err, nats.ErrAccountAuthExpired),
+		strings.Contains(strings.ToLower(err.Error()), "permissions violation"):
+		return Authorization
+	case errors.Is(err, nats.ErrNoServers), errors.Is(err, nats.ErrConnectionClosed), errors.As(err, &opErr):
+		return Connection
+	case errors.Is(err, nats.ErrNoResponders), errors.Is(err, nats.ErrNoStreamResponse):
+		return NoResponders
+	case errors.Is(err, nats.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, nats.ErrJetStreamNotEnabled), errors.Is(err, nats.ErrJetStreamNotEnabledForAccount):
+		return JetStreamNotEnabled
+	case errors.Is(err, ErrNotFound), errors.Is(err, nats.ErrStreamNotFound), errors.Is(err, nats.ErrConsumerNotFound):
+		return NotFound
+	}
+	return Error
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package subject matches subjects against patterns with the NATS wildcards:
+// "*" for any one token and ">" for one or more trailing tokens.
+package subject
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Match reports whether subj matches pattern.
+func Match(pattern, subj string) bool {
+	pts, sts := strings.Split(pattern, "."), strings.Split(subj, ".")
+	for i, pt := range pts {
+		switch {
+		case pt == ">" && i == len(pts)-1:
+			return len(sts) > i
+		case i >= len(sts):
+			return false
+		case pt != "*" && pt != sts[i]:
+			return false
+		}
+	}
+	return len(pts) == len(sts)
+}
+
+// Overlap reports whether some subject matches both patterns a and b.
+func Overlap(a, b string) bool {
+	ats, bts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(ats) && i < len(bts); i++ {
+		at, bt := ats[i], bts[i]
+		switch {
+		case at == ">" || bt == ">":
+			return true
+		case at != "*" && bt != "*" && at != bt:
+			return false
+		}
+	}
+	return len(ats) == len(bts)
+}
+
+// List is a repeatable flag of subjects.
+type List []string
+
+func (l *List) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ", ")
+}
+
+func (l *List) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// Any reports whether subj matches any of the patterns of l.
+func (l List) Any(subj string) bool {
+	for _, pattern := range l {
+		if Match(pattern, subj) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule rewrites the subjects matching a pattern, such as "prod.>" to
+// "staging.>", the wildcards of the pattern filling those of the result in
+// order.
+type Rule struct {
+	From, To string
+}
+
+// ParseRule parses a rule written "from -> to", both well-formed subjects
+// with ">" only as their last token. The result may only have as many "*" as
+// the pattern, and a ">" if the pattern has one.
+func ParseRule(s string) (Rule, error) {
+	from, to, ok := strings.Cut(s, "->")
+	r := Rule{From: strings.TrimSpace(from), To: strings.TrimSpace(to)}
+	if !ok || r.From == "" || r.To == "" {
+		return Rule{}, fmt.Errorf("rule %q is not written from -> to", s)
+	}
+	if err := check(r.From); err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %v", s, err)
+	}
+	if err := check(r.To); err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %v", s, err)
+	}
+	if count(r.To, "*") > count(r.From, "*") {
+		return Rule{}, fmt.Errorf("rule %q has more * in the result than in the pattern", s)
+	}
+	if count(r.To, ">") > count(r.From, ">") {
+		return Rule{}, fmt.Errorf("rule %q has > in the result but not in the pattern", s)
+	}
+	return r, nil
+}
+
+// check returns an error unless subj is a well-formed subject, of tokens
+// neither empty nor with spaces, with ">" only as its last token.
+func check(subj string) error {
+	tokens := strings.Split(subj, ".")
+	for i, t := range tokens {
+		switch {
+		case t == "":
+			return fmt.Errorf("subject %q has an empty token", subj)
+		case strings.ContainsAny(t, " \t\r\n"):
+			return fmt.Errorf("subject %q has a space", subj)
+		case t == ">" && i != len(tokens)-1:
+			return fmt.Errorf("subject %q has > before its last token", subj)
+		}
+	}
+	return nil
+}
+
+// Map returns subj rewritten by the rule, and whether subj matches it.
+func (r Rule) Map(subj string) (string, bool) {
+	if !Match(r.From, subj) {
+		return subj, false
+	}
+	pts, sts := strings.Split(r.From, "."), strings.Split(subj, ".")
+	var stars []string
+	rest := ""
+	for i, pt := range pts {
+		switch pt {
+		case "*":
+			stars = append(stars, sts[i])
+		case ">":
+			rest = strings.Join(sts[i:], ".")
+		}
+	}
+	tts := strings.Split(r.To, ".")
+	for i, tt := range tts {
+		switch tt {
+		case "*":
+			tts[i], stars = stars[0], stars[1:]
+		case ">":
+			tts[i] = rest
+		}
+	}
+	return strings.Join(tts, "."), true
+}
+
+// count returns the number of tokens of subj equal to token.
+func count(subj, token string) int {
+	n := 0
+	for _, t := range strings.Split(subj, ".") {
+		if t == token {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subject
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"orders.new", "orders.new", true},
+		{"orders.>", "orders.new", true},
+		{"orders.>", "orders", false},
+		{"orders.*", "orders.new", true},
+		{"orders.*", "orders.new.x", false},
+		{"orders.*.x", "orders.a.*", true},
+		{"orders.*.x", "orders.a.y", false},
+		{">", "billing.failed", true},
+		{"*.>", "orders", false},
+		{"orders.>", "billing.>", false},
+		{"a.b", "a.b.c", false},
+	} {
+		if got := Overlap(tc.a, tc.b); got != tc.want {
+			t.Errorf("Overlap(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+		if got := Overlap(tc.b, tc.a); got != tc.want {
+			t.Errorf("Overlap(%q, %q) = %v, want %v", tc.b, tc.a, got, tc.want)
+		}
+	}
+}
+
+func TestRuleMap(t *testing.T) {
+	for _, tc := range []struct {
+		rule, subj string
+		want       string
+		ok         bool
+	}{
+		{"prod.> -> staging.>", "prod.orders.new", "staging.orders.new", true},
+		{"prod.> -> staging.>", "prod.orders", "staging.orders", true},
+		{"prod.> -> staging.>", "prod", "prod", false},
+		{"prod.> -> staging.>", "dev.orders", "dev.orders", false},
+		{"a.*.c -> x.*", "a.b.c", "x.b", true},
+		{"a.*.c -> x.*", "a.b.d", "a.b.d", false},
+		{"a.*.*.> -> x.*.y.*.>", "a.1.2.3.4", "x.1.y.2.3.4", true},
+		{"a.*.* -> *.b", "a.1.2", "1.b", true},
+		{"a.*.> -> x.>", "a.1.2", "x.2", true},
+		{"a.*.> -> x", "a.1.2", "x", true},
+		{"orders.new -> orders.old", "orders.new", "orders.old", true},
+		{"orders.new -> orders.old", "orders.new.x", "orders.new.x", false},
+	} {
+		r, err := ParseRule(tc.rule)
+		if err != nil {
+			t.Errorf("%s: %v", tc.rule, err)
+			continue
+		}
+		if got, ok := r.Map(tc.subj); got != tc.want || ok != tc.ok {
+			t.Errorf("%s on %s: got %q %v, want %q %v", tc.rule, tc.subj, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		rule string
+		err  string
+	}{
+		{"prod.>", "not written from -> to"},
+		{"-> x", "not written from -> to"},
+		{"a.* -> x.>.y", "has > before its last token"},
+		{"a.> -> x.>.y", "has > before its last token"},
+		{"a.>.b -> x", "has > before its last token"},
+		{"a.* -> x.>", "has > in the result but not in the pattern"},
+		{"a.* -> x.*.*", "more * in the result"},
+		{"a..b -> x", "empty token"},
+		{"a.b -> x.", "empty token"},
+		{"a.b -> x y", "has a space"},
+	} {
+		_, err := ParseRule(tc.rule)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %v, want %q", tc.rule, err, tc.err)
+		}
+	}
+}
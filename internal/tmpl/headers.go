@@ -0,0 +1,105 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tmpl
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Headers are the message headers given by the -H and -headers-file flags
+// of the commands sending messages.
+type Headers struct {
+	// Values are the -H key:value flags, in order.
+	Values []string
+	// File is a JSON file mapping header names to a value or an array of
+	// values.
+	File string
+}
+
+// AddFlags registers the header flags on fs.
+func (h *Headers) AddFlags(fs *flag.FlagSet) {
+	fs.Var((*headerValues)(&h.Values), "H", "Message header as key:value, may be repeated")
+	fs.StringVar(&h.File, "headers-file", "", "JSON file of message headers, mapping names to a value or an array of values")
+}
+
+// Header returns the headers given, nil if none. A header given with -H
+// replaces the values of the same header in -headers-file.
+func (h *Headers) Header() (nats.Header, error) {
+	hdr := nats.Header{}
+	if h.File != "" {
+		if err := readHeaders(h.File, hdr); err != nil {
+			return nil, err
+		}
+	}
+	flags := nats.Header{}
+	for _, v := range h.Values {
+		key, val, ok := strings.Cut(v, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, status.Invalidf("invalid header %q, use key:value", v)
+		}
+		flags.Add(key, strings.TrimSpace(val))
+	}
+	for key, vals := range flags {
+		hdr[key] = vals
+	}
+	if len(hdr) == 0 {
+		return nil, nil
+	}
+	return hdr, nil
+}
+
+// readHeaders adds the headers of the JSON file at path to hdr.
+func readHeaders(path string, hdr nats.Header) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return status.Invalidf("-headers-file %s: %v", path, err)
+	}
+	for key, raw := range m {
+		var val string
+		var vals []string
+		if err := json.Unmarshal(raw, &val); err == nil {
+			vals = []string{val}
+		} else if err := json.Unmarshal(raw, &vals); err != nil {
+			return status.Invalidf("-headers-file %s: header %q is neither a string nor an array of strings", path, key)
+		}
+		hdr[key] = vals
+	}
+	return nil
+}
+
+// headerValues is the repeatable -H flag.
+type headerValues []string
+
+func (v *headerValues) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, ", ")
+}
+
+func (v *headerValues) Set(s string) error {
+	*v = append(*v, s)
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tmpl expands the templates given as the subject, payload and
+// header arguments of the publishing commands, such as "Message {{Count}}".
+// Payloads are read by package payload, rendering those given as templates.
+// Templates use the text/template syntax, with the functions documented in
+// the README.
+package tmpl
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// Msg is a template for the messages published, but for their payload.
+type Msg struct {
+	Subject *Template
+	Header  map[string][]*Template
+}
+
+// ParseMsg parses the subject and header value templates of a message.
+func ParseMsg(subject string, hdr nats.Header) (*Msg, error) {
+	st, err := Parse("subject", subject)
+	if err != nil {
+		return nil, err
+	}
+	m := &Msg{Subject: st, Header: map[string][]*Template{}}
+	for key, vals := range hdr {
+		for _, v := range vals {
+			vt, err := Parse("header "+key, v)
+			if err != nil {
+				return nil, err
+			}
+			m.Header[key] = append(m.Header[key], vt)
+		}
+	}
+	return m, nil
+}
+
+// Render renders the count'th message published, counting from 1, with
+// payload data.
+func (m *Msg) Render(count int, data []byte) (*nats.Msg, error) {
+	subj, err := m.Subject.Render(count)
+	if err != nil {
+		return nil, err
+	}
+	msg := &nats.Msg{Subject: subj, Data: data}
+	for key, vts := range m.Header {
+		if msg.Header == nil {
+			msg.Header = nats.Header{}
+		}
+		for _, vt := range vts {
+			v, err := vt.Render(count)
+			if err != nil {
+				return nil, err
+			}
+			msg.Header.Add(key, v)
+		}
+	}
+	return msg, nil
+}
+
+// Template is a parsed template.
+type Template struct {
+	text  string
+	t     *template.Template
+	count int
+	files map[string]string
+}
+
+// countMod matches the {{Count % n}} shorthand for {{Mod Count n}}, which
+// text/template cannot parse.
+var countMod = regexp.MustCompile(`\{\{(-? *)Count *% *(\d+)( *-?)\}\}`)
+
+// Parse parses text as a template. Text without actions is returned as is
+// when rendered.
+func Parse(name, text string) (*Template, error) {
+	tp := &Template{text: text, files: map[string]string{}}
+	if !strings.Contains(text, "{{") {
+		return tp, nil
+	}
+	text = countMod.ReplaceAllString(text, "{{${1}Mod Count ${2}${3}}}")
+	t, err := template.New(name).Funcs(tp.funcs()).Parse(text)
+	if err != nil {
+		return nil, status.Invalidf("%v", err)
+	}
+	tp.t = t
+	return tp, nil
+}
+
+// Render renders the template for the count'th message published, counting
+// from 1.
+func (tp *Template) Render(count int) (string, error) {
+	if tp.t == nil {
+		return tp.text, nil
+	}
+	tp.count = count
+	var b bytes.Buffer
+	if err := tp.t.Execute(&b, nil); err != nil {
+		return "", status.Invalidf("%v", err)
+	}
+	return b.String(), nil
+}
+
+func (tp *Template) funcs() template.FuncMap {
+	return template.FuncMap{
+		"Count":        func() int { return tp.count },
+		"TimeStamp":    func() string { return time.Now().UTC().Format(time.RFC3339Nano) },
+		"Time":         func(layout string) string { return time.Now().Format(layout) },
+		"Unix":         func() int64 { return time.Now().Unix() },
+		"UnixMilli":    func() int64 { return time.Now().UnixMilli() },
+		"UnixNano":     func() int64 { return time.Now().UnixNano() },
+		"Mod":          mod,
+		"RandomString": randomString,
+		"RandomInt":    randomInt,
+		"UUID":         uuid,
+		"NUID":         nuid.Next,
+		"Hostname":     os.Hostname,
+		"Env":          os.Getenv,
+		"File":         tp.file,
+	}
+}
+
+// file returns the contents of the file at path, read once.
+func (tp *Template) file(path string) (string, error) {
+	if s, ok := tp.files[path]; ok {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	tp.files[path] = string(data)
+	return string(data), nil
+}
+
+func mod(a, b int) (int, error) {
+	if b <= 0 {
+		return 0, fmt.Errorf("Mod by %d is not positive", b)
+	}
+	return a % b, nil
+}
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomString(n int) (string, error) {
+	if n < 0 {
+		return "", fmt.Errorf("RandomString length %d is negative", n)
+	}
+	b := make([]byte, n)
+	for i := range b {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphanumeric))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphanumeric[j.Int64()]
+	}
+	return string(b), nil
+}
+
+func randomInt(min, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("RandomInt range [%d, %d] is empty", min, max)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)-int64(min)+1))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+// uuid returns a random, version 4, UUID.
+func uuid() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// otlpBatch is the most spans sent in one export request.
+const otlpBatch = 100
+
+// otlpExporter sends spans in the background to an OTLP/HTTP endpoint, in
+// the JSON encoding.
+type otlpExporter struct {
+	url     string
+	service string
+	log     *log.Logger
+	client  *http.Client
+	done    chan struct{}
+
+	// mu guards spans against being sent to once closed, as spans may still
+	// finish after the exporter is closed.
+	mu     sync.Mutex
+	spans  chan *Span
+	closed bool
+}
+
+func newOTLPExporter(url, service string, l *log.Logger) *otlpExporter {
+	e := &otlpExporter{
+		url:     url,
+		service: service,
+		log:     l,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		spans:   make(chan *Span, 1024),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// export queues s for export, dropping it if the queue is full or the
+// exporter closed.
+func (e *otlpExporter) export(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.spans <- s:
+	default:
+		e.log.Printf("Dropping span: export queue full")
+	}
+}
+
+// close exports the queued spans and stops the exporter.
+func (e *otlpExporter) close() {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.spans)
+	}
+	e.mu.Unlock()
+	<-e.done
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	for s := range e.spans {
+		batch := []*Span{s}
+	more:
+		for len(batch) < otlpBatch {
+			select {
+			case s, ok := <-e.spans:
+				if !ok {
+					break more
+				}
+				batch = append(batch, s)
+			default:
+				break more
+			}
+		}
+		if err := e.send(batch); err != nil {
+			e.log.Printf("Exporting spans: %v", err)
+		}
+	}
+}
+
+func (e *otlpExporter) send(batch []*Span) error {
+	body, err := json.Marshal(otlpRequest(e.service, batch))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", e.url, resp.Status)
+	}
+	return nil
+}
+
+// The OTLP trace export request, in its JSON encoding.
+type (
+	otlpExportRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		TraceState        string          `json:"traceState,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// otlpKinds maps the span kinds to their OTLP values.
+var otlpKinds = map[string]int{
+	KindServer:   2,
+	KindClient:   3,
+	KindProducer: 4,
+	KindConsumer: 5,
+}
+
+// OTLP status codes.
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+func otlpRequest(service string, spans []*Span) *otlpExportRequest {
+	ss := otlpScopeSpans{Scope: otlpScope{Name: "gonats"}}
+	for _, s := range spans {
+		status := otlpStatus{Code: otlpStatusOK}
+		if s.Error != "" {
+			status = otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		ss.Spans = append(ss.Spans, otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			TraceState:        s.TraceState,
+			Name:              s.Name,
+			Kind:              otlpKinds[s.Kind],
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes: []otlpAttribute{
+				{"messaging.system", otlpValue{"nats"}},
+				{"messaging.destination.name", otlpValue{s.Subject}},
+			},
+			Status: status,
+		})
+	}
+	return &otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{{"service.name", otlpValue{service}}}},
+		ScopeSpans: []otlpScopeSpans{ss},
+	}}}
+}
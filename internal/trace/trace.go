@@ -0,0 +1,234 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace propagates W3C trace context in message headers and exports
+// the spans of the commands, to a JSON lines file or an OTLP/HTTP endpoint.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/jsonl"
+)
+
+// The W3C trace context headers.
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+)
+
+// Span kinds.
+const (
+	KindServer   = "server"
+	KindClient   = "client"
+	KindProducer = "producer"
+	KindConsumer = "consumer"
+)
+
+// Tracer starts spans and exports them once ended.
+type Tracer struct {
+	// File, if set, receives the spans as JSON lines.
+	File string
+	// OTLP, if set, is the OTLP/HTTP traces endpoint receiving the spans,
+	// such as http://localhost:4318/v1/traces.
+	OTLP string
+
+	service string
+	log     *log.Logger
+	file    *jsonl.Writer
+	otlp    *otlpExporter
+}
+
+// New returns a tracer for the spans of service, logging export errors to l.
+func New(service string, l *log.Logger) *Tracer {
+	return &Tracer{service: service, log: l}
+}
+
+// AddFlags registers the tracing flags on fs.
+func (t *Tracer) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&t.File, "trace-file", "", "Append trace spans as JSON lines to file (- for stdout)")
+	fs.StringVar(&t.OTLP, "trace-otlp", "", "Export trace spans to this OTLP/HTTP traces endpoint")
+}
+
+// Enabled reports whether spans are exported, in which case the traces the
+// commands start are sampled.
+func (t *Tracer) Enabled() bool {
+	return t.File != "" || t.OTLP != ""
+}
+
+// Open opens the exporters given by the flags.
+func (t *Tracer) Open() error {
+	if t.File != "" {
+		w, err := jsonl.Create(t.File)
+		if err != nil {
+			return err
+		}
+		t.file = w
+	}
+	if t.OTLP != "" {
+		t.otlp = newOTLPExporter(t.OTLP, t.service, t.log)
+	}
+	return nil
+}
+
+// Close exports any spans not yet exported and closes the exporters.
+func (t *Tracer) Close() error {
+	if t.otlp != nil {
+		t.otlp.close()
+	}
+	if t.file != nil {
+		return t.file.Close()
+	}
+	return nil
+}
+
+// Span is one operation of a trace. A nil Span is valid, and does nothing.
+type Span struct {
+	TraceID    string    `json:"trace_id"`
+	SpanID     string    `json:"span_id"`
+	ParentID   string    `json:"parent_span_id,omitempty"`
+	TraceState string    `json:"trace_state,omitempty"`
+	Service    string    `json:"service"`
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	Subject    string    `json:"subject"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	DurationMS float64   `json:"duration_ms"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+
+	sampled bool
+	t       *Tracer
+}
+
+// Start starts a new trace with a span for an operation on subj. The trace
+// is always propagated, but sampled, and so exported, only if the tracer is
+// enabled.
+func (t *Tracer) Start(name, kind, subj string) *Span {
+	return t.newSpan(name, kind, subj, newID(16), "", "", t.Enabled())
+}
+
+// Continue starts a span for handling msg, continuing the trace given by its
+// headers, returning nil if msg carries no valid trace context.
+func (t *Tracer) Continue(name, kind string, msg *nats.Msg) *Span {
+	if msg.Header == nil {
+		return nil
+	}
+	traceID, parentID, sampled, ok := parseTraceParent(msg.Header.Get(TraceParentHeader))
+	if !ok {
+		return nil
+	}
+	return t.newSpan(name, kind, msg.Subject, traceID, parentID, msg.Header.Get(TraceStateHeader), sampled)
+}
+
+func (t *Tracer) newSpan(name, kind, subj, traceID, parentID, state string, sampled bool) *Span {
+	return &Span{
+		TraceID:    traceID,
+		SpanID:     newID(8),
+		ParentID:   parentID,
+		TraceState: state,
+		Service:    t.service,
+		Name:       name,
+		Kind:       kind,
+		Subject:    subj,
+		Start:      time.Now().UTC(),
+		sampled:    sampled,
+		t:          t,
+	}
+}
+
+// Inject sets the trace context headers of msg, making this span the parent
+// of its handling.
+func (s *Span) Inject(msg *nats.Msg) {
+	if s == nil {
+		return
+	}
+	if msg.Header == nil {
+		msg.Header = nats.Header{}
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	msg.Header.Set(TraceParentHeader, fmt.Sprintf("00-%s-%s-%s", s.TraceID, s.SpanID, flags))
+	if s.TraceState != "" {
+		msg.Header.Set(TraceStateHeader, s.TraceState)
+	}
+}
+
+// Finish ends the span, failed if err is not nil, and exports it if sampled.
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.End = time.Now().UTC()
+	s.DurationMS = float64(s.End.Sub(s.Start)) / float64(time.Millisecond)
+	s.Status = "ok"
+	if err != nil {
+		s.Status, s.Error = "error", err.Error()
+	}
+	if !s.sampled {
+		return
+	}
+	if s.t.file != nil {
+		if err := s.t.file.Write(s); err != nil {
+			s.t.log.Printf("Writing span: %v", err)
+		}
+	}
+	if s.t.otlp != nil {
+		s.t.otlp.export(s)
+	}
+}
+
+// parseTraceParent parses a version 00 traceparent header value.
+func parseTraceParent(v string) (traceID, parentID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) {
+		return "", "", false, false
+	}
+	traceID, parentID = parts[1], parts[2]
+	if !isHex(parts[0]) || !isHex(traceID) || len(traceID) != 32 || !isHex(parentID) || len(parentID) != 16 ||
+		!isHex(parts[3]) || len(parts[3]) != 2 ||
+		traceID == strings.Repeat("0", 32) || parentID == strings.Repeat("0", 16) {
+		return "", "", false, false
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return traceID, parentID, flags[0]&1 == 1, true
+}
+
+// isHex reports whether s is lowercase hexadecimal, as W3C trace context
+// requires.
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newID returns a random ID of n bytes, hex encoded.
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -14,128 +14,10 @@
 package main
 
 import (
-	"encoding/json"
-	"flag"
-	"github.com/nats-io/nats.go"
-	"log"
-	"os"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
 )
 
-func usage() {
-	log.Printf("Usage: nats-js-addstream [-s server] [-creds file] [-nkey file] [-tlscert file] [-tlskey file] [-tlscacert file] <streamname> <subfilter>\n")
-	flag.PrintDefaults()
-}
-
-func showUsageAndExit(exitcode int) {
-	usage()
-	os.Exit(exitcode)
-}
-
 func main() {
-	var urls = flag.String("s", nats.DefaultURL, "The nats server URLs (separated by comma)")
-	var userCreds = flag.String("creds", "", "User Credentials File")
-	var nkeyFile = flag.String("nkey", "", "NKey Seed File")
-	var tlsClientCert = flag.String("tlscert", "", "TLS client certificate file")
-	var tlsClientKey = flag.String("tlskey", "", "Private key file for client certificate")
-	var tlsCACert = flag.String("tlscacert", "", "CA certificate to verify peer against")
-	var showHelp = flag.Bool("h", false, "Show help message")
-
-	log.SetFlags(0)
-	flag.Usage = usage
-	flag.Parse()
-
-	if *showHelp {
-		showUsageAndExit(0)
-	}
-
-	args := flag.Args()
-	if len(args) != 2 {
-		showUsageAndExit(1)
-	}
-
-	// Connect Options.
-	opts := []nats.Option{nats.Name("NATS JetStream Sample Publisher")}
-
-	if *userCreds != "" && *nkeyFile != "" {
-		log.Fatal("specify -seed or -creds")
-	}
-
-	// Use UserCredentials
-	if *userCreds != "" {
-		opts = append(opts, nats.UserCredentials(*userCreds))
-	}
-
-	// Use TLS client authentication
-	if *tlsClientCert != "" && *tlsClientKey != "" {
-		opts = append(opts, nats.ClientCert(*tlsClientCert, *tlsClientKey))
-	}
-
-	// Use specific CA certificate
-	if *tlsCACert != "" {
-		opts = append(opts, nats.RootCAs(*tlsCACert))
-	}
-
-	// Use Nkey authentication.
-	if *nkeyFile != "" {
-		opt, err := nats.NkeyOptionFromSeed(*nkeyFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		opts = append(opts, opt)
-	}
-
-	// Connect to NATS
-	nc, err := nats.Connect(*urls, opts...)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer nc.Close()
-
-	// Create JetStream Context from NATS connection
-	js, err := nc.JetStream()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	str, subFilter := args[0], args[1]
-
-	// Create a Stream
-	strInfo, err := js.AddStream(&nats.StreamConfig{
-		Name:     str,
-		Subjects: []string{subFilter},
-	})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	/* Stream configuration options (required)
-	{
-	  "name": "interest11",
-	  "subjects": [
-	    "foo11.*"
-	  ],
-	  "retention": "limits",
-	  "max_consumers": -1,
-	  "max_msgs": -1,
-	  "max_bytes": -1,
-	  "discard": "old",
-	  "max_age": 0,
-	  "max_msgs_per_subject": -1,
-	  "max_msg_size": -1,
-	  "storage": "file",
-	  "num_replicas": 1,
-	  "duplicate_window": 120000000000
-	}
-	*/
-
-	// Pretty print our happy result to show all defaults etc.
-	if strInfo != nil {
-		strCfg := strInfo.Config
-		jsonCfg, err := json.MarshalIndent(&strCfg, "", "  ")
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("%s", string(jsonCfg))
-
-	}
+	cli.Main(jsaddstream.Command)
 }
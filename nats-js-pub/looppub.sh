@@ -1,8 +1,13 @@
 #!/bin/bash
 
+# Create the context once with:
+# ./nats-context add -s nats://vbox1.tinghus.net:4222 -creds "/home/todd/lab/nats-cluster1/vault/.nkeys/creds/NatsOp/AcctA/UserA1.creds" cluster1
+# {{Count}} counts the messages of one invocation, so the loop numbers its
+# messages itself, passing the number in the environment.
+n=0
 while :
 do
-   ./nats-js-pub -s nats://vbox1.tinghus.net:4222 -creds "/home/todd/lab/nats-cluster1/vault/.nkeys/creds/NatsOp/AcctA/UserA1.creds" foo "Message {{Count}}"
+   n=$((n+1))
+   MSG_NUM=$n ./nats-js-pub -context cluster1 foo 'Message {{Env "MSG_NUM"}} at {{TimeStamp}} from {{Hostname}}'
    sleep 2 
 done
-
@@ -14,108 +14,10 @@
 package main
 
 import (
-	"flag"
-	"log"
-	"os"
-	"time"
-
-	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/jspubasync"
 )
 
-func usage() {
-	log.Printf("Usage: nats-js-pubasync [-s server] [-creds file] [-nkey file] [-tlscert file] [-tlskey file] [-tlscacert file] <subject> <msg>\n")
-	flag.PrintDefaults()
-}
-
-func showUsageAndExit(exitcode int) {
-	usage()
-	os.Exit(exitcode)
-}
-
 func main() {
-	var urls = flag.String("s", nats.DefaultURL, "The nats server URLs (separated by comma)")
-	var userCreds = flag.String("creds", "", "User Credentials File")
-	var nkeyFile = flag.String("nkey", "", "NKey Seed File")
-	var tlsClientCert = flag.String("tlscert", "", "TLS client certificate file")
-	var tlsClientKey = flag.String("tlskey", "", "Private key file for client certificate")
-	var tlsCACert = flag.String("tlscacert", "", "CA certificate to verify peer against")
-	var showHelp = flag.Bool("h", false, "Show help message")
-
-	log.SetFlags(0)
-	flag.Usage = usage
-	flag.Parse()
-
-	if *showHelp {
-		showUsageAndExit(0)
-	}
-
-	args := flag.Args()
-	if len(args) != 2 {
-		showUsageAndExit(1)
-	}
-
-	// Connect Options.
-	opts := []nats.Option{nats.Name("NATS JetStream Sample Publisher")}
-
-	if *userCreds != "" && *nkeyFile != "" {
-		log.Fatal("specify -seed or -creds")
-	}
-
-	// Use UserCredentials
-	if *userCreds != "" {
-		opts = append(opts, nats.UserCredentials(*userCreds))
-	}
-
-	// Use TLS client authentication
-	if *tlsClientCert != "" && *tlsClientKey != "" {
-		opts = append(opts, nats.ClientCert(*tlsClientCert, *tlsClientKey))
-	}
-
-	// Use specific CA certificate
-	if *tlsCACert != "" {
-		opts = append(opts, nats.RootCAs(*tlsCACert))
-	}
-
-	// Use Nkey authentication.
-	if *nkeyFile != "" {
-		opt, err := nats.NkeyOptionFromSeed(*nkeyFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		opts = append(opts, opt)
-	}
-
-	// Connect to NATS
-	nc, err := nats.Connect(*urls, opts...)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer nc.Close()
-
-	// Create JetStream Context from NATS connection
-	js, err := nc.JetStream()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	subj, msg := args[0], []byte(args[1])
-
-	// Asynchronous publish - a publish acknowledgement future is returned
-	// Since JetStreams cannot overlap subject filter namespace, subject is sufficient to publish
-	// into a stream.
-	paf, err := js.PublishAsync(subj, msg)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Test for an acknowledgement returned from stream
-	select {
-	case pa := <-paf.Ok():
-		log.Printf("Published [%s]: '%s'\nStream: [%s], Seq: [%v]", subj, msg, pa.Stream, pa.Sequence)
-	case err := <-paf.Err():
-		log.Fatal(err)
-		// e.g. JetStream not available for subject: "nats: no responders available for request"
-	case <-time.After(5 * time.Second):
-		log.Fatal("Timeout")
-	}
+	cli.Main(jspubasync.Command)
 }
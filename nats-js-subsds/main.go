@@ -14,161 +14,10 @@
 package main
 
 import (
-	"flag"
-	"log"
-	"os"
-	"os/signal"
-	"time"
-
-	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/jssub"
 )
 
-func usage() {
-	log.Printf("Usage: nats-js-subsds [-s server] [-creds file] [-nkey file] [-tlscert file] [-tlskey file] [-tlscacert file] [-t] <stream> <consumer>\n")
-	flag.PrintDefaults()
-}
-
-func showUsageAndExit(exitcode int) {
-	usage()
-	os.Exit(exitcode)
-}
-
-func printMsg(m *nats.Msg, i int) {
-	md, err := m.Metadata()
-	if err != nil {
-		log.Fatal(err)
-	}
-	log.Printf("[%d]: %s\nSeqPair: [%v] Pending: [%d]", i, m.Subject, md.Sequence, md.NumPending)
-}
-
 func main() {
-	var urls = flag.String("s", nats.DefaultURL, "The nats server URLs (separated by comma)")
-	var userCreds = flag.String("creds", "", "User Credentials File")
-	var nkeyFile = flag.String("nkey", "", "NKey Seed File")
-	var tlsClientCert = flag.String("tlscert", "", "TLS client certificate file")
-	var tlsClientKey = flag.String("tlskey", "", "Private key file for client certificate")
-	var tlsCACert = flag.String("tlscacert", "", "CA certificate to verify peer against")
-	var showTime = flag.Bool("t", false, "Display timestamps")
-	var showHelp = flag.Bool("h", false, "Show help message")
-
-	log.SetFlags(0)
-	flag.Usage = usage
-	flag.Parse()
-
-	if *showHelp {
-		showUsageAndExit(0)
-	}
-
-	args := flag.Args()
-	if len(args) != 2 {
-		showUsageAndExit(1)
-	}
-
-	// Connect Options.
-	opts := []nats.Option{nats.Name("NATS Sample JS Subscriber")}
-	opts = setupConnOptions(opts)
-
-	if *userCreds != "" && *nkeyFile != "" {
-		log.Fatal("specify -seed or -creds")
-	}
-
-	// Use UserCredentials
-	if *userCreds != "" {
-		opts = append(opts, nats.UserCredentials(*userCreds))
-	}
-
-	// Use TLS client authentication
-	if *tlsClientCert != "" && *tlsClientKey != "" {
-		opts = append(opts, nats.ClientCert(*tlsClientCert, *tlsClientKey))
-	}
-
-	// Use specific CA certificate
-	if *tlsCACert != "" {
-		opts = append(opts, nats.RootCAs(*tlsCACert))
-	}
-
-	// Use Nkey authentication.
-	if *nkeyFile != "" {
-		opt, err := nats.NkeyOptionFromSeed(*nkeyFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		opts = append(opts, opt)
-	}
-
-	// Connect to NATS
-	nc, err := nats.Connect(*urls, opts...)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer nc.Close()
-
-	// Create JetStream Context from NATS connection
-	js, err := nc.JetStream()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	str, con := args[0], args[1]
-
-	// Even though we are using Bind(), the method requires us to specify a queueGroup parameter that matches with the
-	// bound Push JS Consumer so we look that up.
-
-	i := 0
-	_, err = js.QueueSubscribe("", getConsumerDeliverGroup(js, str, con), func(msg *nats.Msg) {
-		i += 1
-		printMsg(msg, i)
-	}, nats.Bind(str, con))
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	log.Printf("Listening on stream [%s], consumer [%s]", str, con)
-	if *showTime {
-		log.SetFlags(log.LstdFlags)
-	}
-
-	// Setup the interrupt handler to drain so we don't miss
-	// requests when scaling down.
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
-	log.Println()
-	log.Printf("Draining...")
-	nc.Drain()
-	log.Fatalf("Exiting")
-}
-
-func getConsumerDeliverGroup(js nats.JetStreamContext, str string, con string) string {
-	ci, err := js.ConsumerInfo(str, con)
-	if err != nil {
-		log.Fatal(err)
-	}
-	if ci.Config.DeliverSubject == "" {
-		log.Fatalf("JS Consumer [%s] is not an SDS consumer", con)
-	}
-	return ci.Config.DeliverGroup
-}
-
-func setupConnOptions(opts []nats.Option) []nats.Option {
-	totalWait := 10 * time.Minute
-	reconnectDelay := time.Second
-
-	opts = append(opts, nats.ReconnectWait(reconnectDelay))
-	opts = append(opts, nats.MaxReconnects(int(totalWait/reconnectDelay)))
-	opts = append(opts, nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
-		if err != nil {
-			log.Printf("Disconnected due to:%s, will attempt reconnects for %.0fm", err, totalWait.Minutes())
-		}
-	}))
-	opts = append(opts, nats.ReconnectHandler(func(nc *nats.Conn) {
-		log.Printf("Reconnected [%s]", nc.ConnectedUrl())
-	}))
-	opts = append(opts, nats.ClosedHandler(func(nc *nats.Conn) {
-		if nc.LastError() != nil {
-			log.Fatalf("Exiting: %v", nc.LastError())
-		}
-	}))
-	return opts
+	cli.Main(jssub.Command)
 }
@@ -14,84 +14,10 @@
 package main
 
 import (
-	"flag"
-	"log"
-	"os"
-	"time"
-
-	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
 )
 
-// NOTE: Can test with demo servers.
-// nats-req -s demo.nats.io <subject> <msg>
-// nats-req -s demo.nats.io:4443 <subject> <msg> (TLS version)
-
-func usage() {
-	log.Printf("Usage: nats-req [-s server] [-creds file] [-nkey file] <subject> <msg>\n")
-	flag.PrintDefaults()
-}
-
-func showUsageAndExit(exitcode int) {
-	usage()
-	os.Exit(exitcode)
-}
-
 func main() {
-	var urls = flag.String("s", nats.DefaultURL, "The nats server URLs (separated by comma)")
-	var userCreds = flag.String("creds", "", "User Credentials File")
-	var nkeyFile = flag.String("nkey", "", "NKey Seed File")
-	var showHelp = flag.Bool("h", false, "Show help message")
-
-	log.SetFlags(0)
-	flag.Usage = usage
-	flag.Parse()
-
-	if *showHelp {
-		showUsageAndExit(0)
-	}
-
-	args := flag.Args()
-	if len(args) < 2 {
-		showUsageAndExit(1)
-	}
-
-	// Connect Options.
-	opts := []nats.Option{nats.Name("NATS Sample Requestor")}
-
-	if *userCreds != "" && *nkeyFile != "" {
-		log.Fatal("specify -seed or -creds")
-	}
-
-	// Use UserCredentials
-	if *userCreds != "" {
-		opts = append(opts, nats.UserCredentials(*userCreds))
-	}
-
-	// Use Nkey authentication.
-	if *nkeyFile != "" {
-		opt, err := nats.NkeyOptionFromSeed(*nkeyFile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		opts = append(opts, opt)
-	}
-
-	// Connect to NATS
-	nc, err := nats.Connect(*urls, opts...)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer nc.Close()
-	subj, payload := args[0], []byte(args[1])
-
-	msg, err := nc.Request(subj, payload, 2*time.Second)
-	if err != nil {
-		if nc.LastError() != nil {
-			log.Fatalf("%v for request", nc.LastError())
-		}
-		log.Fatalf("%v for request", err)
-	}
-
-	log.Printf("Published [%s] : '%s'", subj, payload)
-	log.Printf("Received  [%v] : '%s'", msg.Subject, string(msg.Data))
+	cli.Main(req.Command)
 }
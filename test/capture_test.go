@@ -0,0 +1,123 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// readCapture returns the messages of the capture files matching pattern,
+// in the order of their names.
+func readCapture(t *testing.T, pattern string) ([]string, []output.Message) {
+	t.Helper()
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	var msgs []output.Message
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(file, ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var m output.Message
+			if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+			msgs = append(msgs, m)
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		f.Close()
+	}
+	return files, msgs
+}
+
+func TestSubCapture(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+	dir := t.TempDir()
+
+	// Rotated by size, gzipped, and appended to by a second capture.
+	file := filepath.Join(dir, "cap.jsonl.gz")
+	for run := 0; run < 2; run++ {
+		sb := startCommand(t, sub.Command, "-s", url, "-count", "10", "-capture", file, "-capture-size", "600", "-capture-gzip", "capture.>")
+		sb.waitFor(t, "Listening on [capture.>]")
+		mustRun(t, "Published 10 msgs", pub.Command, "-s", url, "-count", "10", "-H", "Run:{{Count}}", "capture.{{Count}}", `{"order":{{Count}}}`)
+		sb.waitFor(t, "Received 10 msgs")
+		if err := sb.exited(t); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, msgs := readCapture(t, filepath.Join(dir, "cap*.jsonl.gz"))
+	if len(files) < 3 || files[len(files)-1] != file {
+		t.Fatalf("not rotated: %v", files)
+	}
+	if len(msgs) != 20 {
+		t.Fatalf("captured %d messages", len(msgs))
+	}
+	for i, m := range msgs {
+		n := i%10 + 1
+		if m.Type != "message" || m.Seq != n || m.Subject != "capture."+strconv.Itoa(n) || m.Data != `{"order":`+strconv.Itoa(n)+`}` ||
+			m.Headers.Get("Run") != strconv.Itoa(n) || m.Time.IsZero() {
+			t.Fatalf("bad captured message %d: %+v", i, m)
+		}
+	}
+
+	// Rotated by age, binary payloads in base64.
+	file = filepath.Join(dir, "age.jsonl")
+	sb := startCommand(t, sub.Command, "-s", url, "-capture", file, "-capture-age", "200ms", "capture.age")
+	sb.waitFor(t, "Listening on [capture.age]")
+	mustRun(t, "Published", pub.Command, "-s", url, "-encoding", "hex", "capture.age", "00ff0a")
+	sb.waitFor(t, "Received on [capture.age]")
+	time.Sleep(300 * time.Millisecond)
+	mustRun(t, "Published", pub.Command, "-s", url, "capture.age", "later")
+	sb.waitFor(t, "Body: 'later'")
+	if err := sb.interrupt(t); err != nil {
+		t.Fatal(err)
+	}
+	files, msgs = readCapture(t, filepath.Join(dir, "age*.jsonl"))
+	if len(files) != 2 || len(msgs) != 2 || string(msgs[0].DataBase64) != "\x00\xff\n" || msgs[1].Data != "later" {
+		t.Fatalf("bad capture by age %v: %+v", files, msgs)
+	}
+
+	if out, err := runCommand(t, sub.Command, "-s", url, "-capture-gzip", "capture.bad"); status.Of(err) != status.Invalid {
+		t.Fatalf("-capture-gzip without -capture: %v\n%s", err, out)
+	}
+}
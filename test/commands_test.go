@@ -0,0 +1,473 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/echo"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddconsumer"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jsfetch"
+	"github.com/tbeets/gonats-101/internal/cmd/jsfetchforever"
+	"github.com/tbeets/gonats-101/internal/cmd/jspub"
+	"github.com/tbeets/gonats-101/internal/cmd/jspubasync"
+	"github.com/tbeets/gonats-101/internal/cmd/jssub"
+	"github.com/tbeets/gonats-101/internal/cmd/microhello"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/qsub"
+	"github.com/tbeets/gonats-101/internal/cmd/reply"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/cmd/reqmulti"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// mustRun runs a command in-process, failing the test unless it succeeds
+// with output containing want.
+func mustRun(t *testing.T, want string, c *cli.Command, args ...string) string {
+	t.Helper()
+	out, err := runCommand(t, c, args...)
+	if err != nil || !strings.Contains(out, want) {
+		t.Fatalf("%s %s: %v\n%s", c.Binary, strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+// jsConnect connects to s for checking the JetStream state directly.
+func jsConnect(t *testing.T, s *server.Server) nats.JetStreamContext {
+	t.Helper()
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js
+}
+
+func TestPubSub(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sb := startCommand(t, sub.Command, "-s", url, "cmd.>")
+	sb.waitFor(t, "Listening on [cmd.>]")
+
+	mustRun(t, "Published [cmd.one]", pub.Command, "-s", url, "cmd.one", "first")
+	sb.waitFor(t, "[#1] Received on [cmd.one]")
+	sb.waitFor(t, "Body: 'first'")
+
+	mustRun(t, `"type":"published"`, pub.Command, "-s", url, "-json", "-reply", "cmd.reply", "cmd.two", "second")
+	sb.waitFor(t, "[#2] Received on [cmd.two]")
+	sb.waitFor(t, "Body: 'second'")
+}
+
+func TestQueueGroup(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	members := []*tool{
+		startCommand(t, qsub.Command, "-s", url, "cmd.work", "workers"),
+		startCommand(t, qsub.Command, "-s", url, "cmd.work", "workers"),
+	}
+	for _, m := range members {
+		m.waitFor(t, "Listening on [cmd.work], queue group [workers]")
+	}
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		mustRun(t, "Published", pub.Command, "-s", url, "cmd.work", fmt.Sprintf("job %d", i))
+	}
+
+	// Each message goes to exactly one member, and both get some.
+	counts := make([]int, len(members))
+	timeout := time.After(5 * time.Second)
+	for total := 0; total < n; {
+		select {
+		case line := <-members[0].lines:
+			if strings.Contains(line, "Queue[workers]") {
+				counts[0]++
+				total++
+			}
+		case line := <-members[1].lines:
+			if strings.Contains(line, "Queue[workers]") {
+				counts[1]++
+				total++
+			}
+		case <-timeout:
+			t.Fatalf("received %v of %d messages", counts, n)
+		}
+	}
+	if counts[0] == 0 || counts[1] == 0 {
+		t.Fatalf("messages not distributed across the group: %v", counts)
+	}
+	for i, m := range members {
+		select {
+		case line := <-m.lines:
+			if strings.Contains(line, "Queue[workers]") {
+				t.Fatalf("member %d received an extra message: %s", i, line)
+			}
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func TestRequestReply(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	rp := startCommand(t, reply.Command, "-s", url, "cmd.help", "on its way")
+	rp.waitFor(t, "Listening on [cmd.help]")
+
+	mustRun(t, "Received  [_INBOX.", req.Command, "-s", url, "cmd.help", "help!")
+	rp.waitFor(t, "Received on [cmd.help]: 'help!'")
+
+	out := mustRun(t, `"type":"reply"`, req.Command, "-s", url, "-json", "cmd.help", "again")
+	if !strings.Contains(out, "on its way") {
+		t.Fatalf("reply missing the response:\n%s", out)
+	}
+
+	ec := startCommand(t, echo.Command, "-s", url, "cmd.echo")
+	ec.waitFor(t, "Echo Service listening on [cmd.echo]")
+	mustRun(t, "'hello echo'", req.Command, "-s", url, "cmd.echo", "hello echo")
+
+	// Both the responder and the echo service answer on the wildcard subject.
+	rp2 := startCommand(t, reply.Command, "-s", url, "-q", "other", "cmd.*", "from reply")
+	rp2.waitFor(t, "Listening on [cmd.*]")
+	out = mustRun(t, "from reply", reqmulti.Command, "-s", url, "-d", "1", "-m", "2", "cmd.echo", "all of you")
+	if !strings.Contains(out, "all of you") {
+		t.Fatalf("req-multi missing the echo reply:\n%s", out)
+	}
+}
+
+func TestJetStreamPublish(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, `"name": "ORDERS"`, jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+	mustRun(t, `"durable_name": "pull"`, jsaddconsumer.Command, "-s", url, "ORDERS", "pull", "orders.new")
+
+	mustRun(t, "Stream: [ORDERS], Seq: [1]", jspub.Command, "-s", url, "orders.new", "one")
+	mustRun(t, "Stream: [ORDERS], Seq: [2]", jspubasync.Command, "-s", url, "orders.new", "two")
+	mustRun(t, `"seq":3`, jspub.Command, "-s", url, "-json", "orders.old", "three")
+
+	js := jsConnect(t, s)
+	si, err := js.StreamInfo("ORDERS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if si.State.Msgs != 3 {
+		t.Fatalf("stream has %d messages, want 3", si.State.Msgs)
+	}
+	ci, err := js.ConsumerInfo("ORDERS", "pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.Config.FilterSubject != "orders.new" || ci.NumPending != 2 {
+		t.Fatalf("consumer filter %q with %d pending, want orders.new with 2", ci.Config.FilterSubject, ci.NumPending)
+	}
+
+	// Publishing outside of any stream is not acknowledged.
+	if out, err := runCommand(t, jspub.Command, "-s", url, "nostream", "lost"); err == nil {
+		t.Fatalf("js pub without a stream succeeded:\n%s", out)
+	}
+}
+
+func TestJetStreamPullFetch(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+	mustRun(t, "pull", jsaddconsumer.Command, "-s", url, "ORDERS", "pull", "orders.new")
+	for i := 1; i <= 3; i++ {
+		mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "orders.new", fmt.Sprintf("order %d", i))
+	}
+
+	out := mustRun(t, "SeqPair: [{2 2}]", jsfetch.Command, "-s", url, "-bs", "2", "ORDERS", "pull")
+	if strings.Count(out, "orders.new") != 2 {
+		t.Fatalf("fetched other than 2 messages:\n%s", out)
+	}
+
+	js := jsConnect(t, s)
+	ci, err := js.ConsumerInfo("ORDERS", "pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.AckFloor.Consumer != 2 || ci.NumAckPending != 0 || ci.NumPending != 1 {
+		t.Fatalf("after fetch: ack floor %d, %d ack pending, %d pending", ci.AckFloor.Consumer, ci.NumAckPending, ci.NumPending)
+	}
+
+	ff := startCommand(t, jsfetchforever.Command, "-s", url, "-json", "ORDERS", "pull")
+	ff.waitFor(t, `"data":"order 3"`)
+	mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "orders.new", "order 4")
+	ff.waitFor(t, `"data":"order 4"`)
+}
+
+func TestJetStreamPushSubscribe(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+
+	// Push consumers have a static deliver subject (SDS), which no command
+	// creates.
+	js := jsConnect(t, s)
+	if _, err := js.AddConsumer("ORDERS", &nats.ConsumerConfig{
+		Durable:        "push",
+		DeliverSubject: "deliver.orders",
+		DeliverGroup:   "pushers",
+		FilterSubject:  "orders.new",
+		AckPolicy:      nats.AckExplicitPolicy,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := startCommand(t, jssub.Command, "-s", url, "ORDERS", "push")
+	ps.waitFor(t, "Listening on stream [ORDERS], consumer [push]")
+
+	mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "orders.new", "pushed")
+	ps.waitFor(t, "[1]: orders.new")
+
+	// Messages outside the consumer filter are not delivered.
+	mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "orders.old", "filtered")
+	mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "orders.new", "pushed again")
+	ps.waitFor(t, "[2]: orders.new")
+
+	if _, err := runCommand(t, jssub.Command, "-s", url, "ORDERS", "nope"); err == nil {
+		t.Fatal("js sub bound to a missing consumer")
+	}
+}
+
+func TestMicroService(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	svc := startCommand(t, microhello.Command, "-s", url)
+	svc.waitFor(t, `Started service "MicroHelloService"`)
+
+	mustRun(t, "A hearty micro Hello to ya'", req.Command, "-s", url, "hello", "")
+
+	// The service answers the micro discovery requests.
+	mustRun(t, "MicroHelloService", req.Command, "-s", url, "$SRV.PING", "")
+}
+
+func TestPubRepeat(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sb := startCommand(t, sub.Command, "-s", url, "repeat.>")
+	sb.waitFor(t, "Listening on [repeat.>]")
+
+	out := mustRun(t, "Published 5 msgs, 20 bytes", pub.Command, "-s", url, "-count", "5", "-rate", "200", "repeat.rate", "#{{Count}}/5")
+	sb.waitFor(t, "[#5] Received on [repeat.rate]")
+	sb.waitFor(t, "Body: '#5/5'")
+	if n := strings.Count(out, "Published [repeat.rate]"); n != 5 {
+		t.Fatalf("published %d messages:\n%s", n, out)
+	}
+
+	start := time.Now()
+	out = mustRun(t, `"type":"publish_summary"`, pub.Command, "-s", url, "-json", "-count", "3", "-interval", "50ms", "repeat.interval", "x")
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Fatalf("3 messages 50ms apart published in %v", d)
+	}
+	var sum output.PublishSummary
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &sum); err != nil || len(lines) != 4 || sum.Msgs != 3 || sum.Bytes != 3 {
+		t.Fatalf("bad summary (%v):\n%s", err, out)
+	}
+
+	// With -duration and no -count, publishing goes on until the duration
+	// elapses.
+	out = mustRun(t, "Published", pub.Command, "-s", url, "-duration", "200ms", "-interval", "20ms", "repeat.duration", "x")
+	if n := strings.Count(out, "Published [repeat.duration]"); n < 3 || n > 11 {
+		t.Fatalf("published %d messages in 200ms at 20ms intervals:\n%s", n, out)
+	}
+
+	// Without a limit, publishing goes on until interrupted.
+	pb := startCommand(t, pub.Command, "-s", url, "-count", "0", "-interval", "10ms", "repeat.forever", "x")
+	pb.waitFor(t, "Published [repeat.forever]")
+	if err := pb.interrupt(t); err != nil {
+		t.Fatal(err)
+	}
+	pb.waitFor(t, "msgs/sec")
+
+	if out, err := runCommand(t, pub.Command, "-s", url, "-interval", "1s", "-rate", "5", "repeat.bad", "x"); status.Of(err) != status.Invalid {
+		t.Fatalf("-interval with -rate: %v\n%s", err, out)
+	}
+}
+
+func TestPubFanout(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sb := startCommand(t, sub.Command, "-s", url, "fanout.>")
+	sb.waitFor(t, "Listening on [fanout.>]")
+
+	out := mustRun(t, "Published 8 msgs", pub.Command, "-s", url, "-count", "8", "fanout.{{Count % 4}}", "x")
+	for _, want := range []string{"[fanout.0]: 2 msgs", "[fanout.1]: 2 msgs", "[fanout.2]: 2 msgs", "[fanout.3]: 2 msgs"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q:\n%s", want, out)
+		}
+	}
+	sb.waitFor(t, "Received on [fanout.3]")
+
+	// A list of subjects, with commas inside template actions kept.
+	out = mustRun(t, `"type":"publish_summary"`, pub.Command, "-s", url, "-json", "-count", "6",
+		`fanout.a,fanout.b,fanout.{{Time "Jan 2, 2006" | len}}`, "x")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	var sum output.PublishSummary
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &sum); err != nil {
+		t.Fatal(err)
+	}
+	if len(sum.Subjects) != 3 || sum.Subjects["fanout.a"] != 2 || sum.Subjects["fanout.b"] != 2 {
+		t.Fatalf("bad per-subject counts %v", sum.Subjects)
+	}
+
+	// Hashing the same key always picks the same subject.
+	out = mustRun(t, "Published 5 msgs", pub.Command, "-s", url, "-count", "5", "-distribution", "hash", "-key", "customer-7",
+		"fanout.x,fanout.y,fanout.z", "x")
+	if strings.Contains(out, "]: 5 msgs") {
+		t.Fatalf("one subject reported per-subject counts:\n%s", out)
+	}
+	if n := strings.Count(out, "Published [fanout."); n != 5 {
+		t.Fatalf("published %d messages:\n%s", n, out)
+	}
+	subjects := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		if i := strings.Index(line, "Published [fanout."); i >= 0 {
+			subjects[line[i:strings.Index(line, "]")]] = true
+		}
+	}
+	if len(subjects) != 1 {
+		t.Fatalf("same key published on %d subjects:\n%s", len(subjects), out)
+	}
+
+	mustRun(t, "Published 10 msgs", pub.Command, "-s", url, "-count", "10", "-distribution", "random", "fanout.r1,fanout.r2", "x")
+
+	for _, args := range [][]string{
+		{"-distribution", "sticky", "fanout.a,fanout.b", "x"},
+		{"-key", "k", "fanout.a,fanout.b", "x"},
+		{"fanout.{{Count % 0}}", "x"},
+	} {
+		if out, err := runCommand(t, pub.Command, append([]string{"-s", url}, args...)...); status.Of(err) != status.Invalid {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+// exited waits for the command to exit on its own and returns its error.
+func (tl *tool) exited(t *testing.T) error {
+	t.Helper()
+	select {
+	case <-tl.done:
+		return tl.err
+	case <-time.After(5 * time.Second):
+		t.Fatal("command did not exit")
+		return nil
+	}
+}
+
+func TestSubExitConditions(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sb := startCommand(t, sub.Command, "-s", url, "-count", "3", "exit.count")
+	sb.waitFor(t, "Listening on [exit.count]")
+	mustRun(t, "Published 5 msgs", pub.Command, "-s", url, "-count", "5", "exit.count", "#{{Count}}")
+	sb.waitFor(t, "Received 3 msgs, 6 bytes")
+	if err := sb.exited(t); err != nil {
+		t.Fatalf("sub -count: %v", err)
+	}
+
+	out, err := runCommand(t, sub.Command, "-s", url, "-timeout", "100ms", "exit.timeout")
+	if status.Of(err) != status.Timeout || !strings.Contains(err.Error(), "no message received in 100ms") || !strings.Contains(out, "Received 0 msgs") {
+		t.Fatalf("sub -timeout: %v\n%s", err, out)
+	}
+
+	qs := startCommand(t, qsub.Command, "-s", url, "-json", "-idle", "300ms", "exit.idle", "q")
+	qs.waitFor(t, `"type":"subscribed"`)
+	mustRun(t, "Published 2 msgs", pub.Command, "-s", url, "-count", "2", "exit.idle", "x")
+	if line := qs.waitFor(t, `"type":"receive_summary"`); !strings.Contains(line, `"msgs":2,"bytes":2`) {
+		t.Fatalf("bad summary %s", line)
+	}
+	if err := qs.exited(t); err != nil {
+		t.Fatalf("qsub -idle: %v", err)
+	}
+
+	qs = startCommand(t, qsub.Command, "-s", url, "-count", "2", "-timeout", "500ms", "exit.partial", "q")
+	qs.waitFor(t, "Listening on [exit.partial]")
+	mustRun(t, "Published [exit.partial]", pub.Command, "-s", url, "exit.partial", "x")
+	qs.waitFor(t, "Received 1 msgs")
+	if err := qs.exited(t); status.Of(err) != status.Timeout || !strings.Contains(err.Error(), "received 1 of 2 messages") {
+		t.Fatalf("qsub -count -timeout: %v", err)
+	}
+
+	out, err = runTool(t, "nats-sub", "-s", url, "-timeout", "100ms", "exit.binary")
+	if code := exitCode(t, err); code != status.Timeout {
+		t.Fatalf("nats-sub -timeout exited with %d:\n%s", code, out)
+	}
+}
+
+func TestSubMultipleSubjects(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sb := startCommand(t, sub.Command, "-s", url, "-exclude", "orders.heartbeat", "--exclude", "*.*.debug", "orders.>", "billing.*.failed")
+	sb.waitFor(t, "Listening on [orders.>]")
+	sb.waitFor(t, "Listening on [billing.*.failed]")
+	for _, subj := range []string{"orders.new", "orders.heartbeat", "billing.eu.failed", "billing.eu.ok", "orders.eu.debug", "orders.eu.new"} {
+		mustRun(t, "Published ["+subj+"]", pub.Command, "-s", url, subj, "x")
+	}
+	sb.waitFor(t, "[#1] Received on [orders.new]")
+	sb.waitFor(t, "[#2] Received on [billing.eu.failed]")
+	sb.waitFor(t, "[#3] Received on [orders.eu.new]")
+	if err := sb.interrupt(t); err != nil {
+		t.Fatal(err)
+	}
+	sb.waitFor(t, "Received 3 msgs, 3 bytes")
+	sb.waitFor(t, "[billing.*.failed]: 1 msgs")
+	sb.waitFor(t, "[orders.>]: 2 msgs")
+	sb.waitFor(t, "excluded: 2 msgs")
+
+	// -count counts the messages of every subject.
+	sb = startCommand(t, sub.Command, "-s", url, "-json", "-count", "2", "a.*", "b.*")
+	sb.waitFor(t, `"subject":"b.*"`)
+	mustRun(t, "Published 2 msgs", pub.Command, "-s", url, "-count", "2", "a.1,b.1", "x")
+	line := sb.waitFor(t, `"type":"receive_summary"`)
+	if !strings.Contains(line, `"msgs":2,`) || !strings.Contains(line, `"subjects":{"a.*":1,"b.*":1}`) {
+		t.Fatalf("bad summary %s", line)
+	}
+	if err := sb.exited(t); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overlapping subjects would handle a message twice.
+	for _, subjs := range [][]string{{"orders.>", "orders.new"}, {"a.*.x", "a.b.*"}, {"a.b", "a.b"}} {
+		out, err := runCommand(t, sub.Command, append([]string{"-s", url}, subjs...)...)
+		if status.Of(err) != status.Invalid || !strings.Contains(err.Error(), "overlap") {
+			t.Fatalf("overlapping %v: %v\n%s", subjs, err, out)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/cmd/contexts"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// TestEmptyEnvironment runs a command without $HOME nor $XDG_CONFIG_HOME, as
+// in a scratch container: no context is then selected.
+func TestEmptyEnvironment(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	run := func(args ...string) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, filepath.Join(binDir, "nats-pub"), args...)
+		cmd.Env = []string{}
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	if out, err := run("-show-config"); err != nil || !strings.Contains(out, "context") {
+		t.Fatalf("-show-config: %v\n%s", err, out)
+	}
+	if out, err := run("-s", s.ClientURL(), "empty.env", "hello"); err != nil || !strings.Contains(out, "Published [empty.env]") {
+		t.Fatalf("publishing: %v\n%s", err, out)
+	}
+	// A context asked for explicitly still needs the configuration directory.
+	if out, err := run("-context", "demo", "-s", s.ClientURL(), "empty.env", "hello"); err == nil {
+		t.Fatalf("-context without a configuration directory succeeded:\n%s", out)
+	}
+}
+
+func TestContexts(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	run := configured(t)
+	must := func(want string, args ...string) string {
+		t.Helper()
+		out, err := run(contexts.Command, args...)
+		if err != nil || !strings.Contains(out, want) {
+			t.Fatalf("%v: %v, want %q in:\n%s", args, err, want, out)
+		}
+		return out
+	}
+
+	must("no contexts", "ls")
+	must("no context selected", "select")
+	must("Saved context [demo]", "add", "-description", "Local server", "-s", s.ClientURL(), "demo")
+	must("Saved context [secret]", "add", "-s", "nats://127.0.0.1:1", "-user", "u", "-password", "hunter2", "secret")
+	if out := must("  demo\tLocal server", "ls"); !strings.Contains(out, "  secret") {
+		t.Fatalf("ls lacks secret:\n%s", out)
+	}
+
+	// Selecting.
+	must("Selected context [demo]", "select", "demo")
+	must("Selected context [demo]", "select")
+	must("* demo\tLocal server", "ls")
+	if out := must(`"url": "`+s.ClientURL()+`"`, "show"); !strings.Contains(out, "Context [demo]") {
+		t.Fatalf("show shows no name:\n%s", out)
+	}
+	if out := must(`"password": "[REDACTED]"`, "show", "secret"); strings.Contains(out, "hunter2") {
+		t.Fatalf("show shows the password:\n%s", out)
+	}
+	out := must(`"type":"context"`, "-json", "ls")
+	var selected []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var c output.Context
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			t.Fatalf("bad context %q: %v", line, err)
+		}
+		if c.Selected {
+			selected = append(selected, c.Name)
+		}
+	}
+	if len(selected) != 1 || selected[0] != "demo" {
+		t.Fatalf("selected %v", selected)
+	}
+
+	// Commands connect through the selected context, or the one asked for.
+	if out, err := run(pub.Command, "ctx.selected", "x"); err != nil || !strings.Contains(out, "Published [ctx.selected]") {
+		t.Fatalf("publishing through the selected context: %v\n%s", err, out)
+	}
+	must("Selected context [secret]", "select", "secret")
+	if out, err := run(pub.Command, "-context", "demo", "ctx.flag", "x"); err != nil || !strings.Contains(out, "Published [ctx.flag]") {
+		t.Fatalf("publishing through -context: %v\n%s", err, out)
+	}
+	t.Setenv("NATS_CONTEXT", "demo")
+	if out, err := run(pub.Command, "ctx.env", "x"); err != nil || !strings.Contains(out, "Published [ctx.env]") {
+		t.Fatalf("publishing through NATS_CONTEXT: %v\n%s", err, out)
+	}
+	t.Setenv("NATS_CONTEXT", "")
+	if out, err := run(pub.Command, "-context", "missing", "ctx.missing", "x"); status.Of(err) != status.NotFound {
+		t.Fatalf("missing context: %v\n%s", err, out)
+	}
+
+	// Removing the selected context unselects it.
+	must("Removed context [secret]", "rm", "secret")
+	must("no context selected", "select")
+	if out, err := run(contexts.Command, "rm", "secret"); status.Of(err) != status.NotFound {
+		t.Fatalf("removing a missing context: %v\n%s", err, out)
+	}
+	if out, err := run(contexts.Command, "select", "secret"); status.Of(err) != status.NotFound {
+		t.Fatalf("selecting a missing context: %v\n%s", err, out)
+	}
+	if out := must("  demo", "ls"); strings.Contains(out, "secret") {
+		t.Fatalf("ls lists a removed context:\n%s", out)
+	}
+}
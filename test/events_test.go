@@ -0,0 +1,104 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/conn"
+)
+
+// readEvents returns the events of the -events file, once it has n.
+func readEvents(t *testing.T, file string, n int) []conn.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(file)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(data) > 0 && len(lines) >= n {
+			events := make([]conn.Event, len(lines))
+			for i, line := range lines {
+				if err := json.Unmarshal([]byte(line), &events[i]); err != nil {
+					t.Fatalf("bad event %q: %v", line, err)
+				}
+			}
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d events, want %d:\n%s", len(lines), n, data)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestConnectionEvents(t *testing.T) {
+	s1 := runServer(t, testServerOptions())
+	addr := s1.Addr().String()
+	_, port, _ := net.SplitHostPort(addr)
+	file := filepath.Join(t.TempDir(), "events.jsonl")
+
+	sb := startCommand(t, sub.Command, "-s", "nats://u:p4ss@"+addr, "-events", file,
+		"-reconnect-wait", "50ms", "-reconnect-jitter", "0", "events.test")
+	sb.waitFor(t, "Listening on [events.test]")
+	readEvents(t, file, 1)
+
+	// Restart the server on the same port.
+	s1.Shutdown()
+	readEvents(t, file, 2)
+	opts := testServerOptions()
+	opts.Port, _ = strconv.Atoi(port)
+	s2 := runServer(t, opts)
+	sb.waitFor(t, "Reconnected")
+	readEvents(t, file, 3)
+	if err := sb.interrupt(t); err != nil {
+		t.Fatal(err)
+	}
+
+	events := readEvents(t, file, 5)
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+		if e.Name != "NATS Sample Subscriber" || e.Time.IsZero() {
+			t.Fatalf("bad event %+v", e)
+		}
+	}
+	// Closing the connection disconnects it first, with no error.
+	if strings.Join(types, ",") != "connected,disconnected,reconnected,disconnected,closed" {
+		t.Fatalf("events %v", types)
+	}
+	connected, disconnected, reconnected, closed := events[0], events[1], events[2], events[4]
+	if connected.ServerID != s1.ID() || !strings.Contains(connected.URL, addr) || strings.Contains(connected.URL, "p4ss") {
+		t.Fatalf("bad connected event %+v", connected)
+	}
+	if disconnected.URL != "" || disconnected.ServerID != "" || disconnected.Error == "" || events[3].Error != "" {
+		t.Fatalf("bad disconnected event %+v", disconnected)
+	}
+	if reconnected.ServerID != s2.ID() || !strings.Contains(reconnected.URL, addr) {
+		t.Fatalf("bad reconnected event %+v", reconnected)
+	}
+	if closed.URL != "" || closed.ServerID != "" || closed.Error != "" {
+		t.Fatalf("bad closed event %+v", closed)
+	}
+	if !events[0].Time.Before(events[4].Time) {
+		t.Fatalf("events out of order: %+v", events)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jspub"
+	"github.com/tbeets/gonats-101/internal/cmd/jssub"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/qsub"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+func TestSubFilter(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	for _, tc := range []struct {
+		filter string
+		first  string // the first message matching
+	}{
+		{`header.Tenant == "acme"`, "#1"},
+		{`body =~ "fail(ed|ure)"`, "#2"},
+		{`.status == "failed"`, "#2"},
+		{`.amount > 100`, "#2"},
+		{`.status != "failed" && (.amount >= 150 || header["Tenant"] == "acme")`, "#1"},
+		{`.items[1].sku == "b" || !.status`, "#1"},
+		{`subject =~ "\\.eu$" && !.amount`, "#4"},
+		{`.amount <= 100`, "#1"},
+	} {
+		sb := startCommand(t, sub.Command, "-s", url, "-count", "1", "-timeout", "1s", "-filter", tc.filter, "filter.>")
+		sb.waitFor(t, "Listening on [filter.>]")
+		// Of the messages published, only the first matching is printed,
+		// -count then reached.
+		for i, hdr := range []string{"acme", "globex", "acme", ""} {
+			body := []string{
+				`{"id":"#1","status":"ok","amount":50,"items":[{"sku":"a"},{"sku":"b"}]}`,
+				`{"id":"#2","status":"failed","amount":150}`,
+				`{"id":"#3","status":"ok","amount":200}`,
+				`#4 failure`,
+			}[i]
+			subj := []string{"filter.us", "filter.us", "filter.us", "filter.eu"}[i]
+			args := []string{"-s", url, subj, body}
+			if hdr != "" {
+				args = append([]string{"-H", "Tenant:" + hdr}, args...)
+			}
+			mustRun(t, "Published", pub.Command, args...)
+		}
+		sb.waitFor(t, "Body: '"+firstBody(tc.first))
+		sb.waitFor(t, "Received 1 msgs")
+		if err := sb.exited(t); err != nil {
+			t.Fatalf("%s: %v", tc.filter, err)
+		}
+	}
+
+	// Counts of the messages matched and dropped.
+	qs := startCommand(t, qsub.Command, "-s", url, "-idle", "300ms", "-filter", ".n > 1", "filter.q", "q")
+	qs.waitFor(t, "Listening on [filter.q]")
+	mustRun(t, "Published 3 msgs", pub.Command, "-s", url, "-count", "3", "filter.q", `{"n":{{Count}}}`)
+	qs.waitFor(t, "Received 2 msgs")
+	qs.waitFor(t, "dropped by -filter: 1 msgs")
+
+	for _, expr := range []string{`.status ==`, `unknown == 1`, `body =~ "("`, `header.Tenant = "acme"`, `(.a`} {
+		if out, err := runCommand(t, sub.Command, "-s", url, "-filter", expr, "filter.bad"); status.Of(err) != status.Invalid {
+			t.Fatalf("%s: %v\n%s", expr, err, out)
+		}
+	}
+}
+
+// firstBody returns the start of the body of the message with id.
+func firstBody(id string) string {
+	if id == "#4" {
+		return "#4 failure"
+	}
+	return `{"id":"` + id + `"`
+}
+
+func TestJetStreamSubFilter(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+	js := jsConnect(t, s)
+	if _, err := js.AddConsumer("ORDERS", &nats.ConsumerConfig{
+		Durable:        "push",
+		DeliverSubject: "deliver.orders",
+		DeliverGroup:   "pushers",
+		AckPolicy:      nats.AckExplicitPolicy,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := startCommand(t, jssub.Command, "-s", url, "-filter", `.status == "failed"`, "ORDERS", "push")
+	ps.waitFor(t, "Listening on stream [ORDERS], consumer [push]")
+	mustRun(t, "Seq: [1]", jspub.Command, "-s", url, "orders.new", `{"status":"ok"}`)
+	mustRun(t, "Seq: [2]", jspub.Command, "-s", url, "orders.new", `{"status":"failed"}`)
+	ps.waitFor(t, "[1]: orders.new")
+
+	// Dropped messages are acknowledged too.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ci, err := js.ConsumerInfo("ORDERS", "push")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ci.AckFloor.Stream == 2 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("ack floor %d", ci.AckFloor.Stream)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := ps.interrupt(t); err != nil {
+		t.Fatal(err)
+	}
+	ps.waitFor(t, "dropped by -filter: 1 msgs")
+}
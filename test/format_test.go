@@ -0,0 +1,116 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// startCommandStdout runs a long-running command in-process like
+// startCommand, its stdout kept apart from the log lines of the tool.
+func startCommandStdout(t *testing.T, c *cli.Command, args ...string) (*tool, *syncBuffer) {
+	t.Helper()
+	isolate(t)
+	r, w := io.Pipe()
+	stdout := &syncBuffer{}
+	stop := make(chan struct{})
+	tl := &tool{lines: make(chan string, 1024), stop: func() { close(stop) }, done: make(chan struct{})}
+	go tl.scanLines(r)
+	go func() {
+		tl.err = cli.Run(c, args, stdout, w, stop)
+		w.Close()
+		close(tl.done)
+	}()
+	t.Cleanup(func() { tl.interrupt(t) })
+	return tl, stdout
+}
+
+// waitForOutput waits for out to satisfy done, returning its contents.
+func waitForOutput(t *testing.T, out *syncBuffer, done func(string) bool) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done(out.String()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for output, got:\n%s", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return out.String()
+}
+
+func TestSubFormat(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	raw, rawOut := startCommandStdout(t, sub.Command, "-s", url, "-format", "raw", "fmt.>")
+	raw.waitFor(t, "Listening on [fmt.>]")
+	js, jsOut := startCommandStdout(t, sub.Command, "-s", url, "-format", "json", "fmt.>")
+	js.waitFor(t, "Listening on [fmt.>]")
+	hex, hexOut := startCommandStdout(t, sub.Command, "-s", url, "-format", "hex", "fmt.>")
+	hex.waitFor(t, "Listening on [fmt.>]")
+	tp, tpOut := startCommandStdout(t, sub.Command, "-s", url, "-format", `{{.Seq}} {{.Subject}} {{.Size}} {{.Header.Get "K"}}`, "fmt.>")
+	tp.waitFor(t, "Listening on [fmt.>]")
+
+	mustRun(t, "Published [fmt.text]", pub.Command, "-s", url, "-H", "K:v", "fmt.text", "hello\n")
+	mustRun(t, "Published [fmt.bin]", pub.Command, "-s", url, "-encoding", "hex", "fmt.bin", "00ff0a")
+
+	// Raw payloads are written as they are, with nothing in between.
+	if out := waitForOutput(t, rawOut, func(s string) bool { return len(s) >= 9 }); out != "hello\n\x00\xff\n" {
+		t.Fatalf("raw output %q", out)
+	}
+
+	out := waitForOutput(t, jsOut, func(s string) bool { return strings.Count(s, "\n") == 2 })
+	var msgs [2]output.Message
+	for i, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if err := json.Unmarshal([]byte(line), &msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m := msgs[0]; m.Subject != "fmt.text" || m.Data != "hello\n" || m.Size != 6 || m.Headers.Get("K") != "v" || m.Time.IsZero() {
+		t.Fatalf("bad JSON message %+v", m)
+	}
+	if m := msgs[1]; m.Subject != "fmt.bin" || string(m.DataBase64) != "\x00\xff\n" {
+		t.Fatalf("bad JSON binary message %+v", m)
+	}
+
+	out = waitForOutput(t, hexOut, func(s string) bool { return strings.Contains(s, "fmt.bin") && strings.HasSuffix(s, "\n") })
+	if !strings.Contains(out, "[#2] Received on [fmt.bin]: 3 bytes\n00000000  00 ff 0a") {
+		t.Fatalf("bad hex dump:\n%s", out)
+	}
+
+	out = waitForOutput(t, tpOut, func(s string) bool { return strings.Count(s, "\n") == 2 })
+	if out != "1 fmt.text 6 v\n2 fmt.bin 3 \n" {
+		t.Fatalf("bad template output %q", out)
+	}
+
+	for _, args := range [][]string{
+		{"-format", "xml", "fmt.bad"},
+		{"-format", "{{.Subject", "fmt.bad"},
+		{"-json", "-format", "raw", "fmt.bad"},
+	} {
+		if out, err := runCommand(t, sub.Command, append([]string{"-s", url}, args...)...); status.Of(err) != status.Invalid {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+}
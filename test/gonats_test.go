@@ -0,0 +1,80 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGonatsSubcommands(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sub := startTool(t, "gonats", "sub", "-s", url, "gonats.test")
+	sub.waitFor(t, "Listening on [gonats.test]")
+
+	// Global flags apply to the subcommand.
+	out, err := runTool(t, "gonats", "-s", url, "pub", "gonats.test", "global flags")
+	if err != nil {
+		t.Fatalf("gonats pub: %v\n%s", err, out)
+	}
+	sub.waitFor(t, "Body: 'global flags'")
+
+	out, err = runTool(t, "gonats", "pub", "-s", url, "-json", "gonats.test", "command flags")
+	if err != nil || !strings.Contains(out, `"type":"published"`) {
+		t.Fatalf("gonats pub -json: %v\n%s", err, out)
+	}
+	sub.waitFor(t, "Body: 'command flags'")
+
+	respond(t, s, "gonats.svc", "pong")
+	out, err = runTool(t, "gonats", "req", "-s", url, "gonats.svc", "ping")
+	if err != nil || !strings.Contains(out, "'pong'") {
+		t.Fatalf("gonats req: %v\n%s", err, out)
+	}
+}
+
+func TestGonatsHelp(t *testing.T) {
+	out, err := runTool(t, "gonats", "help", "js", "pub")
+	if err != nil || !strings.Contains(out, "Usage: gonats js pub") {
+		t.Fatalf("gonats help js pub: %v\n%s", err, out)
+	}
+
+	out, err = runTool(t, "gonats", "js")
+	if err == nil || !strings.Contains(out, "js add-stream") {
+		t.Fatalf("gonats js did not list the js commands: %v\n%s", err, out)
+	}
+
+	out, err = runTool(t, "gonats", "nope")
+	if err == nil || !strings.Contains(out, `unknown command "nope"`) {
+		t.Fatalf("gonats nope: %v\n%s", err, out)
+	}
+}
+
+func TestGonatsCompletion(t *testing.T) {
+	for shell, want := range map[string]string{
+		"bash": "complete -F _gonats gonats",
+		"zsh":  "compdef _gonats gonats",
+		"fish": "complete -c gonats -n '__gonats_using js' -a add-stream",
+	} {
+		out, err := runTool(t, "gonats", "completion", shell)
+		if err != nil || !strings.Contains(out, want) {
+			t.Fatalf("gonats completion %s: %v\n%s", shell, err, out)
+		}
+	}
+
+	if out, err := runTool(t, "gonats", "completion", "tcsh"); err == nil {
+		t.Fatalf("gonats completion tcsh succeeded:\n%s", out)
+	}
+}
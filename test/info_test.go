@@ -0,0 +1,92 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cmd/info"
+	"github.com/tbeets/gonats-101/internal/output"
+)
+
+// runInfo runs nats-info with -json and returns the info reported.
+func runInfo(t *testing.T, args ...string) *output.Info {
+	t.Helper()
+	out := mustRun(t, `"type":"info"`, info.Command, append([]string{"-json"}, args...)...)
+	var in output.Info
+	if err := json.Unmarshal([]byte(out), &in); err != nil {
+		t.Fatalf("bad info %q: %v", out, err)
+	}
+	return &in
+}
+
+func TestInfo(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	out := mustRun(t, "JetStream:          not enabled", info.Command, "-s", s.ClientURL())
+	for _, want := range []string{"Server ID:          " + s.ID(), "TLS:                none", "over 5 samples"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("info lacks %q:\n%s", want, out)
+		}
+	}
+
+	in := runInfo(t, "-s", s.ClientURL(), "-rtt-samples", "3")
+	if in.ServerID != s.ID() || in.Version == "" || in.MaxPayload != 1024*1024 || !in.Headers ||
+		in.TLS != nil || in.RTT.Samples != 3 || in.RTT.MinMS > in.RTT.AvgMS || in.RTT.AvgMS > in.RTT.MaxMS ||
+		in.JetStream.Enabled {
+		t.Fatalf("bad info: %+v", in)
+	}
+
+	opts := jetStreamServerOptions(t)
+	opts.JetStreamDomain = "hub"
+	js := runServer(t, opts)
+	if in := runInfo(t, "-s", js.ClientURL()); !in.JetStream.Enabled || in.JetStream.Domain != "hub" {
+		t.Fatalf("bad JetStream info: %+v", in.JetStream)
+	}
+
+	// Any other error asking the account info is reported, with the rest.
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	nc.Subscribe("$JS.API.INFO", func(m *nats.Msg) { m.Respond([]byte("not json")) })
+	nc.Flush()
+	out = mustRun(t, "JetStream:          unavailable: ", info.Command, "-s", s.ClientURL())
+	if !strings.Contains(out, "Server ID:          "+s.ID()) {
+		t.Fatalf("info lacks the server:\n%s", out)
+	}
+	if in := runInfo(t, "-s", s.ClientURL()); in.ServerID != s.ID() || in.JetStream.Enabled || in.JetStream.Error == "" {
+		t.Fatalf("bad JetStream info: %+v", in.JetStream)
+	}
+}
+
+func TestInfoTLS(t *testing.T) {
+	cert, caFile := writeCert(t, t.TempDir())
+	opts := testServerOptions()
+	opts.TLS = true
+	opts.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	s := runServer(t, opts)
+
+	in := runInfo(t, "-s", "tls://"+s.Addr().String(), "-tlscacert", caFile)
+	if in.TLS == nil || in.TLS.Version == "" || len(in.TLS.PeerCertificates) != 1 {
+		t.Fatalf("bad TLS info: %+v", in.TLS)
+	}
+	if c := in.TLS.PeerCertificates[0]; c.Subject != "CN=gonats test" || len(c.DNSNames) != 1 {
+		t.Fatalf("bad peer certificate: %+v", c)
+	}
+}
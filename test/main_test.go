@@ -0,0 +1,304 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package test runs the commands end to end against an in-process server.
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/tbeets/gonats-101/internal/cli"
+)
+
+// binDir holds the commands built for the tests.
+var binDir string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gonats-test-bin")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	binDir = dir
+
+	build := exec.Command("go", "build", "-o", binDir+string(filepath.Separator),
+		"./nats-pub", "./nats-sub", "./nats-req", "./gonats")
+	build.Dir = ".."
+	if out, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "building commands: %v\n%s", err, out)
+		os.RemoveAll(binDir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(binDir)
+	os.Exit(code)
+}
+
+// testServerOptions returns options for a server listening on random ports.
+func testServerOptions() *server.Options {
+	return &server.Options{
+		Host:   "127.0.0.1",
+		Port:   -1,
+		NoLog:  true,
+		NoSigs: true,
+	}
+}
+
+// jetStreamServerOptions returns options for a server with JetStream enabled,
+// storing in a temporary directory.
+func jetStreamServerOptions(t *testing.T) *server.Options {
+	opts := testServerOptions()
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	return opts
+}
+
+// runServer starts an in-process server, shut down when the test ends.
+func runServer(t *testing.T, opts *server.Options) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(opts)
+	if err != nil {
+		t.Fatalf("creating server: %v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("server not ready for connections")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+// toolEnv isolates commands from the user's contexts and environment.
+func toolEnv(t *testing.T) []string {
+	env := []string{"XDG_CONFIG_HOME=" + t.TempDir(), "HOME=" + t.TempDir()}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "NATS_") && !strings.HasPrefix(kv, "XDG_CONFIG_HOME=") && !strings.HasPrefix(kv, "HOME=") {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// runTool runs a command to completion and returns its combined output.
+func runTool(t *testing.T, name string, args ...string) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, filepath.Join(binDir, name), args...)
+	cmd.Env = toolEnv(t)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// tool is a long-running command whose output lines can be awaited.
+type tool struct {
+	lines chan string
+
+	stopOnce sync.Once
+	stop     func()
+	done     chan struct{}
+	err      error
+}
+
+// interrupt interrupts the command, as SIGTERM does its binary, and returns
+// its error once it exits.
+func (tl *tool) interrupt(t *testing.T) error {
+	t.Helper()
+	tl.stopOnce.Do(tl.stop)
+	select {
+	case <-tl.done:
+		return tl.err
+	case <-time.After(10 * time.Second):
+		t.Fatal("command not done once interrupted")
+		return nil
+	}
+}
+
+// scanLines sends the lines read from r to the tool, until r ends.
+func (tl *tool) scanLines(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tl.lines <- scanner.Text()
+	}
+	close(tl.lines)
+}
+
+// startTool starts a long-running command, killed when the test ends.
+func startTool(t *testing.T, name string, args ...string) *tool {
+	t.Helper()
+	cmd := exec.Command(filepath.Join(binDir, name), args...)
+	cmd.Env = toolEnv(t)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting %s: %v", name, err)
+	}
+	tl := &tool{lines: make(chan string, 1024), done: make(chan struct{})}
+	tl.stop = func() { cmd.Process.Signal(syscall.SIGTERM) }
+	go func() {
+		tl.scanLines(out)
+		tl.err = cmd.Wait()
+		close(tl.done)
+	}()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-tl.done
+	})
+	return tl
+}
+
+// isolate isolates in-process commands from the user's contexts and
+// environment for the duration of the test.
+func isolate(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	for _, kv := range os.Environ() {
+		if k, _, _ := strings.Cut(kv, "="); strings.HasPrefix(k, "NATS_") {
+			t.Setenv(k, "")
+			os.Unsetenv(k)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// runCommand runs a command in-process to completion and returns its
+// combined output.
+func runCommand(t *testing.T, c *cli.Command, args ...string) (string, error) {
+	t.Helper()
+	isolate(t)
+	var out syncBuffer
+	err := cli.Run(c, args, &out, &out, nil)
+	return out.String(), err
+}
+
+// startCommand runs a long-running command in-process, interrupted when the
+// test ends.
+func startCommand(t *testing.T, c *cli.Command, args ...string) *tool {
+	t.Helper()
+	isolate(t)
+	r, w := io.Pipe()
+	stop := make(chan struct{})
+	tl := &tool{lines: make(chan string, 1024), stop: func() { close(stop) }, done: make(chan struct{})}
+	go tl.scanLines(r)
+	go func() {
+		tl.err = cli.Run(c, args, w, w, stop)
+		w.Close()
+		close(tl.done)
+	}()
+	t.Cleanup(func() { tl.interrupt(t) })
+	return tl
+}
+
+// waitFor waits for an output line containing s.
+func (tl *tool) waitFor(t *testing.T, s string) string {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-tl.lines:
+			if !ok {
+				t.Fatalf("command exited waiting for %q", s)
+			}
+			if strings.Contains(line, s) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for %q", s)
+		}
+	}
+}
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key to
+// dir, returning the TLS certificate and the path of the certificate file.
+func writeCert(t *testing.T, dir string) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gonats test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	certFile := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certFile
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddconsumer"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jsfetchforever"
+	"github.com/tbeets/gonats-101/internal/cmd/jspub"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/reply"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/metrics"
+)
+
+// metricsURL waits for the command to serve its metrics and returns their URL.
+func (tl *tool) metricsURL(t *testing.T) string {
+	t.Helper()
+	line := tl.waitFor(t, "Serving metrics on [")
+	return strings.TrimSuffix(line[strings.Index(line, "[")+1:], "]")
+}
+
+// waitForMetrics scrapes url until every one of the metric lines is served.
+func waitForMetrics(t *testing.T, url string, lines ...string) {
+	t.Helper()
+	var body string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = string(b)
+		missing := false
+		for _, l := range lines {
+			if !strings.Contains(body, "\n"+l+"\n") {
+				missing = true
+			}
+		}
+		if !missing {
+			return
+		}
+	}
+	t.Fatalf("metrics missing some of %q:\n%s", lines, body)
+}
+
+func TestMetrics(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sb := startCommand(t, sub.Command, "-s", url, "-metrics-addr", "127.0.0.1:0", "metrics.sub")
+	subMetrics := sb.metricsURL(t)
+	sb.waitFor(t, "Listening on [metrics.sub]")
+
+	mustRun(t, "Published", pub.Command, "-s", url, "metrics.sub", "12345")
+	mustRun(t, "Published", pub.Command, "-s", url, "metrics.sub", "67890")
+	waitForMetrics(t, subMetrics,
+		"gonats_messages_received_total 2",
+		"gonats_bytes_received_total 10",
+		"gonats_messages_sent_total 0",
+		"gonats_handler_duration_seconds_count 2",
+		`gonats_handler_duration_seconds_bucket{le="+Inf"} 2`,
+		"gonats_pending_messages 0",
+		"gonats_reconnects_total 0",
+		"gonats_slow_consumer_dropped_total 0",
+	)
+
+	rp := startCommand(t, reply.Command, "-s", url, "-metrics-addr", "127.0.0.1:0", "metrics.rply", "pong")
+	replyMetrics := rp.metricsURL(t)
+	rp.waitFor(t, "Listening on [metrics.rply]")
+	mustRun(t, "'pong'", req.Command, "-s", url, "metrics.rply", "ping")
+	waitForMetrics(t, replyMetrics,
+		"gonats_messages_received_total 1",
+		"gonats_bytes_received_total 4",
+		"gonats_messages_sent_total 1",
+		"gonats_bytes_sent_total 4",
+	)
+
+	// Commands not running for long do not accept the flag.
+	if out, err := runCommand(t, pub.Command, "-s", url, "-metrics-addr", ":0", "metrics.sub", "x"); err == nil {
+		t.Fatalf("pub accepted -metrics-addr:\n%s", out)
+	}
+}
+
+func TestMetricsJetStreamAcks(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+	mustRun(t, "pull", jsaddconsumer.Command, "-s", url, "ORDERS", "pull", "orders.>")
+
+	ff := startCommand(t, jsfetchforever.Command, "-s", url, "-metrics-addr", "127.0.0.1:0", "ORDERS", "pull")
+	ffMetrics := ff.metricsURL(t)
+	for _, m := range []string{"one", "two", "three"} {
+		mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "orders.new", m)
+	}
+	waitForMetrics(t, ffMetrics,
+		"gonats_messages_received_total 3",
+		"gonats_acks_total 3",
+		"gonats_naks_total 0",
+	)
+}
+
+// TestMetricsDroppedKept checks that the messages dropped by a subscription
+// still count once it is unsubscribed, the counter never going down.
+func TestMetricsDroppedKept(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	nc, err := nats.Connect(s.ClientURL(), nats.ErrorHandler(func(*nats.Conn, *nats.Subscription, error) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	block := make(chan struct{})
+	defer close(block)
+	sb, err := nc.Subscribe("metrics.slow", func(*nats.Msg) { <-block })
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.SetPendingLimits(1, -1)
+	m := metrics.New()
+	m.WatchSub(sb)
+
+	dropped := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest("GET", metrics.Path, nil))
+		for _, line := range strings.Split(rec.Body.String(), "\n") {
+			if strings.HasPrefix(line, "gonats_slow_consumer_dropped_total ") {
+				return strings.TrimPrefix(line, "gonats_slow_consumer_dropped_total ")
+			}
+		}
+		t.Fatalf("no dropped counter:\n%s", rec.Body.String())
+		return ""
+	}
+	for i := 0; i < 10; i++ {
+		nc.Publish("metrics.slow", []byte("x"))
+	}
+	nc.Flush()
+	want := 0
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if n, _ := sb.Dropped(); n >= 8 {
+			want = n
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("messages not dropped")
+		}
+	}
+	if n := dropped(); n != strconv.Itoa(want) {
+		t.Fatalf("dropped %s, want %d", n, want)
+	}
+	sb.Unsubscribe()
+	if n := dropped(); n != strconv.Itoa(want) {
+		t.Fatalf("dropped %s once unsubscribed, want %d", n, want)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jspub"
+	"github.com/tbeets/gonats-101/internal/cmd/jspubasync"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// runToolInput runs a command to completion with stdin and returns its
+// combined output.
+func runToolInput(t *testing.T, stdin, name string, args ...string) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, filepath.Join(binDir, name), args...)
+	cmd.Env = toolEnv(t)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// writeFile writes data to a temporary file and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestPayloadSources(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	sb := startCommand(t, sub.Command, "-s", url, "payload.>")
+	sb.waitFor(t, "Listening on [payload.>]")
+
+	mustRun(t, "Published [payload.file]", pub.Command, "-s", url, "payload.file", "@"+writeFile(t, "doc.json", []byte(`{"doc": "{{Count}}"}`)))
+	// Files are sent as is, not as templates.
+	sb.waitFor(t, `Body: '{"doc": "{{Count}}"}'`)
+
+	mustRun(t, "Published [payload.hex]", pub.Command, "-s", url, "-encoding", "hex", "payload.hex", "68656c6c6f")
+	sb.waitFor(t, "Body: 'hello'")
+
+	mustRun(t, "Published [payload.base64]", pub.Command, "-s", url, "-encoding", "base64", "payload.base64",
+		"@"+writeFile(t, "b64", []byte("d29ybGQ=\n")))
+	sb.waitFor(t, "Body: 'world'")
+
+	out := mustRun(t, "Published 3 msgs", pub.Command, "-s", url, "-lines", "payload.lines", "@"+writeFile(t, "lines", []byte("one\r\ntwo\nthree\n")))
+	if n := strings.Count(out, "Published [payload.lines]"); n != 3 {
+		t.Fatalf("published %d lines:\n%s", n, out)
+	}
+	for _, line := range []string{"one", "two", "three"} {
+		sb.waitFor(t, "Body: '"+line+"'")
+	}
+
+	out, err := runToolInput(t, "from stdin", "nats-pub", "-s", url, "payload.stdin", "-")
+	if err != nil {
+		t.Fatalf("nats-pub from stdin: %v\n%s", err, out)
+	}
+	sb.waitFor(t, "Body: 'from stdin'")
+
+	respond(t, s, "payload.req", "ok")
+	out, err = runToolInput(t, "first\nsecond\n", "nats-req", "-s", url, "-lines", "payload.req", "-")
+	if err != nil || strings.Count(out, "Received  [") != 2 {
+		t.Fatalf("nats-req -lines from stdin: %v\n%s", err, out)
+	}
+
+	for _, args := range [][]string{
+		{"-encoding", "hex", "payload.bad", "not hex"},
+		{"-encoding", "rot13", "payload.bad", "x"},
+		{"-lines", "payload.bad", "inline"},
+	} {
+		if out, err := runCommand(t, req.Command, append([]string{"-s", url}, args...)...); status.Of(err) != status.Invalid {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestPayloadJetStream(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+
+	binary := []byte{0x00, 0xff, 0xfe, '\n', 0x01}
+	mustRun(t, "Stream: [ORDERS], Seq: [1]", jspub.Command, "-s", url, "orders.binary", "@"+writeFile(t, "bin", binary))
+	js := jsConnect(t, s)
+	m, err := js.GetLastMsg("ORDERS", "orders.binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.Data, binary) {
+		t.Fatalf("stored %x, want %x", m.Data, binary)
+	}
+
+	out := mustRun(t, "Seq: [4]", jspubasync.Command, "-s", url, "-lines", "-encoding", "base64", "orders.lines",
+		"@"+writeFile(t, "lines", []byte("b25l\ndHdv\ndGhyZWU=\n")))
+	if n := strings.Count(out, "Stream: [ORDERS]"); n != 3 {
+		t.Fatalf("%d acks:\n%s", n, out)
+	}
+	if m, err := js.GetLastMsg("ORDERS", "orders.lines"); err != nil || string(m.Data) != "three" {
+		t.Fatalf("last line stored: %v %v", m, err)
+	}
+
+	out, err = runToolInput(t, "a\nb\n", "gonats", "js", "pub", "-s", url, "-lines", "orders.stdin", "-")
+	if err != nil || !strings.Contains(out, "Seq: [6]") {
+		t.Fatalf("gonats js pub -lines from stdin: %v\n%s", err, out)
+	}
+
+	// More lines than may await their acknowledgement at once.
+	lines := strings.Repeat("line\n", 1000)
+	out = mustRun(t, "Seq: [1006]", jspubasync.Command, "-s", url, "-lines", "orders.many", "@"+writeFile(t, "many", []byte(lines)))
+	if n := strings.Count(out, "Stream: [ORDERS]"); n != 1000 {
+		t.Fatalf("%d acks of 1000", n)
+	}
+}
+
+func TestPayloadMaxPayload(t *testing.T) {
+	opts := testServerOptions()
+	opts.MaxPayload = 1024
+	s := runServer(t, opts)
+	url := s.ClientURL()
+
+	big := "@" + writeFile(t, "big", bytes.Repeat([]byte("x"), 2048))
+	out, err := runCommand(t, pub.Command, "-s", url, "payload.big", big)
+	if status.Of(err) != status.Invalid || !strings.Contains(err.Error(), "exceeds the server's max payload of 1024 bytes") {
+		t.Fatalf("oversized payload: %v\n%s", err, out)
+	}
+	// The max payload also bounds the headers, here the trace context.
+	mustRun(t, "Published [payload.small]", pub.Command, "-s", url, "payload.small", strings.Repeat("x", 900))
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/replay"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+func TestReplay(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	// Capture 3 messages, 200ms apart.
+	file := filepath.Join(t.TempDir(), "prod.jsonl.gz")
+	sb := startCommand(t, sub.Command, "-s", url, "-count", "3", "-capture", file, "-capture-gzip", "prod.>")
+	sb.waitFor(t, "Listening on [prod.>]")
+	mustRun(t, "Published 3 msgs", pub.Command, "-s", url, "-count", "3", "-interval", "200ms", "-H", "Seq:{{Count}}",
+		"prod.orders.{{Count}}", "order {{Count}}")
+	sb.waitFor(t, "Received 3 msgs")
+	if err := sb.exited(t); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replayed with the original timing, subjects rewritten.
+	st := startCommand(t, sub.Command, "-s", url, "staging.>")
+	st.waitFor(t, "Listening on [staging.>]")
+	start := time.Now()
+	out := mustRun(t, "Replayed 3 msgs", replay.Command, "-s", url, "-map", "prod.* -> other.*", "-map", "prod.> -> staging.>", file)
+	if d := time.Since(start); d < 350*time.Millisecond {
+		t.Fatalf("replayed in %v, captured over 400ms", d)
+	}
+	if !strings.Contains(out, "[staging.orders.3]: 1 msgs") {
+		t.Fatalf("no per-subject counts:\n%s", out)
+	}
+	for i := 1; i <= 3; i++ {
+		st.waitFor(t, "Received on [staging.orders."+strconv.Itoa(i)+"]")
+		st.waitFor(t, "Header: Seq: ["+strconv.Itoa(i)+"]")
+		st.waitFor(t, "Body: 'order "+strconv.Itoa(i)+"'")
+	}
+
+	// As fast as possible, or faster, and from stdin.
+	start = time.Now()
+	mustRun(t, "Replayed 3 msgs", replay.Command, "-s", url, "-speed", "0", file)
+	mustRun(t, "Replayed 3 msgs", replay.Command, "-s", url, "-speed", "100", file)
+	if d := time.Since(start); d > 300*time.Millisecond {
+		t.Fatalf("replayed fast in %v", d)
+	}
+	out, err := runToolInput(t, `{"type":"subscribed","subject":"x"}`+"\n"+
+		`{"type":"message","time":"2023-01-20T16:04:05Z","seq":1,"subject":"prod.bin","size":3,"data_base64":"AP8K"}`+"\n",
+		"gonats", "replay", "-s", url, "-map", "prod.> -> staging.>", "-")
+	if err != nil || !strings.Contains(out, "Replayed 1 msgs, 3 bytes") {
+		t.Fatalf("gonats replay from stdin: %v\n%s", err, out)
+	}
+	st.waitFor(t, "Received on [staging.bin]")
+
+	// Published to JetStream, with acknowledgements.
+	mustRun(t, "STAGING", jsaddstream.Command, "-s", url, "STAGING", "staging.>")
+	mustRun(t, "Stream: [STAGING], Seq: [3]", replay.Command, "-s", url, "-js", "-speed", "0", "-map", "prod.> -> staging.>", file)
+	m, err := jsConnect(t, s).GetLastMsg("STAGING", "staging.orders.2")
+	if err != nil || string(m.Data) != "order 2" || m.Header.Get("Seq") != "2" {
+		t.Fatalf("bad stored message %v: %v", m, err)
+	}
+
+	bad := writeFile(t, "bad.jsonl", []byte("not json\n"))
+	for _, args := range [][]string{
+		{"-map", "prod.* -> staging.*.*", file},
+		{"-map", "prod.*", file},
+		{"-speed", "-1", file},
+		{"-speed", "0", bad},
+	} {
+		if out, err := runCommand(t, replay.Command, append([]string{"-s", url}, args...)...); status.Of(err) != status.Invalid {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+	}
+	if _, err := runCommand(t, replay.Command, "-s", url, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("replayed a missing file")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddconsumer"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jsfetchforever"
+	"github.com/tbeets/gonats-101/internal/cmd/jspub"
+	"github.com/tbeets/gonats-101/internal/cmd/microhello"
+	"github.com/tbeets/gonats-101/internal/cmd/qsub"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+// exitCode returns the exit status of a command that exited with err.
+func exitCode(t *testing.T, err error) int {
+	t.Helper()
+	var exitErr *exec.ExitError
+	if err == nil {
+		return 0
+	} else if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	t.Fatal(err)
+	return -1
+}
+
+func TestShutdownSIGTERM(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	for _, args := range [][]string{{"nats-sub"}, {"gonats", "sub"}} {
+		sub := startTool(t, args[0], append(args[1:], "-s", url, "shutdown.test")...)
+		sub.waitFor(t, "Listening on [shutdown.test]")
+		err := sub.interrupt(t)
+		sub.waitFor(t, "Caught terminated, shutting down")
+		sub.waitFor(t, "Draining...")
+		sub.waitFor(t, "Exiting")
+		if code := exitCode(t, err); code != status.OK {
+			t.Fatalf("%s exited with %d", args, code)
+		}
+	}
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	qs := startCommand(t, qsub.Command, "-s", url, "-drain-timeout", "1ns", "shutdown.test", "q")
+	qs.waitFor(t, "Listening on [shutdown.test]")
+	if err := qs.interrupt(t); !errors.Is(err, nats.ErrDrainTimeout) {
+		t.Fatalf("qsub interrupted: %v", err)
+	}
+
+	sub := startTool(t, "nats-sub", "-s", url, "-drain-timeout", "1ns", "shutdown.test")
+	sub.waitFor(t, "Listening on [shutdown.test]")
+	if code := exitCode(t, sub.interrupt(t)); code != status.DrainTimeout {
+		t.Fatalf("nats-sub exited with %d, want %d", code, status.DrainTimeout)
+	}
+}
+
+func TestShutdownAcksInFlight(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+	mustRun(t, "pull", jsaddconsumer.Command, "-s", url, "ORDERS", "pull", "orders.>")
+
+	ff := startCommand(t, jsfetchforever.Command, "-s", url, "-bs", "10", "ORDERS", "pull")
+	for _, m := range []string{"one", "two", "three"} {
+		mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "orders.new", m)
+	}
+	ff.waitFor(t, "SeqPair: [{3 3}]")
+
+	// Interrupted while fetching, the command stops at once, having sent
+	// every ack.
+	start := time.Now()
+	if err := ff.interrupt(t); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("interrupted fetch took %v", d)
+	}
+	ci, err := jsConnect(t, s).ConsumerInfo("ORDERS", "pull")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ci.AckFloor.Consumer != 3 || ci.NumAckPending != 0 {
+		t.Fatalf("ack floor %d with %d ack pending after shutdown", ci.AckFloor.Consumer, ci.NumAckPending)
+	}
+}
+
+func TestShutdownMicroService(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	svc := startCommand(t, microhello.Command, "-s", url)
+	svc.waitFor(t, `Started service "MicroHelloService"`)
+	mustRun(t, "A hearty micro Hello", req.Command, "-s", url, "hello", "")
+
+	if err := svc.interrupt(t); err != nil {
+		t.Fatal(err)
+	}
+	svc.waitFor(t, `Stopped service "MicroHelloService"`)
+	if out, err := runCommand(t, req.Command, "-s", url, "hello", ""); err == nil {
+		t.Fatalf("stopped service answered:\n%s", out)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/tbeets/gonats-101/internal/cli"
+	"github.com/tbeets/gonats-101/internal/cmd/contexts"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddconsumer"
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jsfetch"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/output"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+func TestExitStatus(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+	js := runServer(t, jetStreamServerOptions(t))
+	jsURL := js.ClientURL()
+	authOpts := testServerOptions()
+	authOpts.Users = []*server.User{{
+		Username:    "limited",
+		Password:    "secret",
+		Permissions: &server.Permissions{Publish: &server.SubjectPermission{Allow: []string{"allowed"}}},
+	}}
+	auth := runServer(t, authOpts)
+	authURL := auth.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", jsURL, "ORDERS", "orders.>")
+	sb := startCommand(t, sub.Command, "-s", url, "silent")
+	sb.waitFor(t, "Listening on [silent]")
+
+	for _, tc := range []struct {
+		name string
+		want int
+		c    *cli.Command
+		args []string
+	}{
+		{"usage", status.Invalid, req.Command, []string{"-s", url, "nobody"}},
+		{"bad flag", status.Invalid, pub.Command, []string{"-no-such-flag"}},
+		{"bad auth flags", status.Invalid, pub.Command, []string{"-s", url, "-password", "secret", "foo", "bar"}},
+		{"no context", status.NotFound, contexts.Command, []string{"show", "missing"}},
+		{"connection refused", status.Connection, pub.Command, []string{"-s", "nats://127.0.0.1:1", "foo", "bar"}},
+		{"bad credentials", status.Authorization, pub.Command, []string{"-s", authURL, "-user", "limited", "-password", "wrong", "foo", "bar"}},
+		{"permissions violation", status.Authorization, req.Command, []string{"-s", authURL, "-user", "limited", "-password", "secret", "denied", "hi"}},
+		{"no responders", status.NoResponders, req.Command, []string{"-s", url, "nobody", "hi"}},
+		{"timeout", status.Timeout, req.Command, []string{"-s", url, "silent", "hi"}},
+		{"jetstream not enabled", status.JetStreamNotEnabled, jsaddstream.Command, []string{"-s", url, "ORDERS", "orders.>"}},
+		{"stream not found", status.NotFound, jsaddconsumer.Command, []string{"-s", jsURL, "MISSING", "pull", "orders.>"}},
+		{"consumer not found", status.NotFound, jsfetch.Command, []string{"-s", jsURL, "ORDERS", "missing"}},
+	} {
+		out, err := runCommand(t, tc.c, tc.args...)
+		if got := status.Of(err); got != tc.want {
+			t.Errorf("%s: %s exited with %d (%v), want %d:\n%s", tc.name, tc.c.Binary, got, err, tc.want, out)
+		}
+	}
+}
+
+func TestExitStatusBinary(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	out, err := runTool(t, "nats-req", "-s", url, "nobody", "hi")
+	if code := exitCode(t, err); code != status.NoResponders {
+		t.Fatalf("nats-req exited with %d, want %d:\n%s", code, status.NoResponders, out)
+	}
+	out, err = runTool(t, "gonats", "req", "-s", url, "nobody")
+	if code := exitCode(t, err); code != status.Invalid || strings.Contains(out, "bad usage") {
+		t.Fatalf("gonats req exited with %d, want %d:\n%s", code, status.Invalid, out)
+	}
+	if _, err := runTool(t, "gonats", "no-such-command"); exitCode(t, err) != status.Invalid {
+		t.Fatalf("unknown command exited with %d", exitCode(t, err))
+	}
+}
+
+func TestExitStatusJSON(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	isolate(t)
+	var stdout, stderr syncBuffer
+	err := cli.Run(req.Command, []string{"-s", url, "-json", "nobody", "hi"}, &stdout, &stderr, nil)
+	if err == nil {
+		t.Fatal("request without responders succeeded")
+	}
+	var e output.Error
+	if err := json.Unmarshal([]byte(stdout.String()), &e); err != nil {
+		t.Fatalf("bad error output %q: %v", stdout.String(), err)
+	}
+	if e.Type != "error" || e.Class != "no_responders" || e.Status != status.NoResponders ||
+		!strings.Contains(e.Error, "no responders") {
+		t.Fatalf("bad error output: %s", stdout.String())
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/tbeets/gonats-101/internal/cmd/jsaddstream"
+	"github.com/tbeets/gonats-101/internal/cmd/jspub"
+	"github.com/tbeets/gonats-101/internal/cmd/jspubasync"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/cmd/reqmulti"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/status"
+)
+
+func TestPublishTemplates(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+	file := filepath.Join(t.TempDir(), "body.txt")
+	if err := os.WriteFile(file, []byte("included"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GONATS_TEST_VAR", "from-env")
+
+	sb := startCommand(t, sub.Command, "-s", url, "tmpl.>")
+	sb.waitFor(t, "Listening on [tmpl.>]")
+
+	mustRun(t, "Published [tmpl.1]", pub.Command, "-s", url, "tmpl.{{Count}}",
+		`{{Count}} {{Env "GONATS_TEST_VAR"}} {{File "`+file+`"}} {{RandomString 8}} {{RandomInt 5 5}} {{UUID}} {{Unix}}`)
+	sb.waitFor(t, "Received on [tmpl.1]")
+	body := sb.waitFor(t, "Body:")
+	if !regexp.MustCompile(`Body: '1 from-env included [a-zA-Z0-9]{8} 5 [0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12} \d+'`).MatchString(body) {
+		t.Fatalf("bad rendered payload: %s", body)
+	}
+
+	// Payloads without actions are sent as is.
+	mustRun(t, "Published [tmpl.plain]", pub.Command, "-s", url, "tmpl.plain", `{"json": true}`)
+	sb.waitFor(t, `Body: '{"json": true}'`)
+
+	for _, payload := range []string{"{{Count", "{{NoSuchFunc}}", `{{File "/no/such/file"}}`} {
+		if _, err := runCommand(t, pub.Command, "-s", url, "tmpl.bad", payload); status.Of(err) != status.Invalid {
+			t.Fatalf("bad template %q: %v", payload, err)
+		}
+	}
+}
+
+func TestJetStreamPublishTemplates(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+	mustRun(t, "Published [orders.1]: 'Message 1'", jspub.Command, "-s", url, "orders.{{Count}}", "Message {{Count}}")
+	mustRun(t, "Published [orders.host]", jspubasync.Command, "-s", url, "orders.host", "{{Hostname}} {{NUID}}")
+
+	m, err := jsConnect(t, s).GetLastMsg("ORDERS", "orders.host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	if !regexp.MustCompile(`^` + regexp.QuoteMeta(host) + ` [0-9A-Za-z]{22}$`).Match(m.Data) {
+		t.Fatalf("bad rendered payload %q", m.Data)
+	}
+}
+
+func TestPublishHeaders(t *testing.T) {
+	s := runServer(t, jetStreamServerOptions(t))
+	url := s.ClientURL()
+	file := filepath.Join(t.TempDir(), "headers.json")
+	if err := os.WriteFile(file, []byte(`{"Source": "file", "Tags": ["a", "b"], "Override": "file"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sb := startCommand(t, sub.Command, "-s", url, "hdr.>")
+	sb.waitFor(t, "Listening on [hdr.>]")
+
+	mustRun(t, "Published [hdr.pub]", pub.Command, "-s", url, "-headers-file", file,
+		"-H", "Override: flag", "-H", "Seq:{{Count}}", "-H", "Seq:again", "hdr.pub", "with headers")
+	sb.waitFor(t, "Received on [hdr.pub]")
+	headers := map[string]bool{}
+	// Those given, and the trace context.
+	for i := 0; i < 5; i++ {
+		headers[sb.waitFor(t, "Header: ")] = true
+	}
+	for _, want := range []string{"Header: Source: [file]", "Header: Tags: [a b]", "Header: Override: [flag]", "Header: Seq: [1 again]"} {
+		if !headers[want] {
+			t.Fatalf("header %q not received in %v", want, headers)
+		}
+	}
+
+	// The subscriber sees the requests, with their headers.
+	respond(t, s, "hdr.req", "ok")
+	mustRun(t, "'ok'", req.Command, "-s", url, "-H", "Requestor:req", "hdr.req", "hi")
+	sb.waitFor(t, "Header: Requestor: [req]")
+	mustRun(t, "ok", reqmulti.Command, "-s", url, "-H", "Requestor:req-multi", "hdr.req", "hi")
+	sb.waitFor(t, "Header: Requestor: [req-multi]")
+
+	mustRun(t, "ORDERS", jsaddstream.Command, "-s", url, "ORDERS", "orders.>")
+	mustRun(t, "Stream: [ORDERS]", jspub.Command, "-s", url, "-H", "Nats-Msg-Id:order-1", "orders.new", "one")
+	// A duplicate message ID is acknowledged without being stored again.
+	mustRun(t, "Stream: [ORDERS]", jspubasync.Command, "-s", url, "-H", "Nats-Msg-Id:order-1", "orders.new", "one")
+	m, err := jsConnect(t, s).GetLastMsg("ORDERS", "orders.new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Sequence != 1 || m.Header.Get("Nats-Msg-Id") != "order-1" {
+		t.Fatalf("bad stored message %d with headers %v", m.Sequence, m.Header)
+	}
+
+	if _, err := runCommand(t, pub.Command, "-s", url, "-H", "no separator", "hdr.bad", "x"); status.Of(err) != status.Invalid {
+		t.Fatalf("bad header: %v", err)
+	}
+}
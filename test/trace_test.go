@@ -0,0 +1,200 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tbeets/gonats-101/internal/cmd/echo"
+	"github.com/tbeets/gonats-101/internal/cmd/pub"
+	"github.com/tbeets/gonats-101/internal/cmd/reply"
+	"github.com/tbeets/gonats-101/internal/cmd/req"
+	"github.com/tbeets/gonats-101/internal/cmd/sub"
+	"github.com/tbeets/gonats-101/internal/trace"
+)
+
+// waitForSpans waits for file to hold n spans and returns them.
+func waitForSpans(t *testing.T, file string, n int) []trace.Span {
+	t.Helper()
+	var spans []trace.Span
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		spans = nil
+		data, _ := os.ReadFile(file)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			var s trace.Span
+			if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+				t.Fatalf("bad span %q: %v", scanner.Text(), err)
+			}
+			spans = append(spans, s)
+		}
+		if len(spans) >= n {
+			break
+		}
+	}
+	if len(spans) != n {
+		t.Fatalf("%s holds %d spans, want %d: %+v", file, len(spans), n, spans)
+	}
+	return spans
+}
+
+func TestTracePropagation(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+	dir := t.TempDir()
+	reqSpans, rplySpans, subSpans := filepath.Join(dir, "req.jsonl"), filepath.Join(dir, "rply.jsonl"), filepath.Join(dir, "sub.jsonl")
+
+	rp := startCommand(t, reply.Command, "-s", url, "-trace-file", rplySpans, "trace.svc", "traced")
+	rp.waitFor(t, "Listening on [trace.svc]")
+	sb := startCommand(t, sub.Command, "-s", url, "-trace-file", subSpans, "trace.>")
+	sb.waitFor(t, "Listening on [trace.>]")
+
+	// The trace context is always propagated, but not sampled, nor exported
+	// downstream, unless the requestor traces.
+	mustRun(t, "'traced'", req.Command, "-s", url, "trace.svc", "untraced")
+	if line := sb.waitFor(t, "Header: traceparent:"); !strings.HasSuffix(line, "-00]") {
+		t.Fatalf("untraced request sampled: %s", line)
+	}
+	sb.waitFor(t, "Body: 'untraced'")
+
+	mustRun(t, "'traced'", req.Command, "-s", url, "-trace-file", reqSpans, "trace.svc", "hello")
+	if line := sb.waitFor(t, "Header: traceparent:"); !strings.HasSuffix(line, "-01]") {
+		t.Fatalf("traced request not sampled: %s", line)
+	}
+
+	root := waitForSpans(t, reqSpans, 1)[0]
+	if root.Name != "request" || root.Kind != trace.KindClient || root.Service != "nats-req" ||
+		root.Subject != "trace.svc" || root.ParentID != "" || root.Status != "ok" || len(root.TraceID) != 32 {
+		t.Fatalf("bad request span: %+v", root)
+	}
+	for _, file := range []string{rplySpans, subSpans} {
+		child := waitForSpans(t, file, 1)[0]
+		if child.TraceID != root.TraceID || child.ParentID != root.SpanID || child.Subject != "trace.svc" {
+			t.Fatalf("span %+v does not continue %+v", child, root)
+		}
+	}
+
+	// A failed request is recorded as such.
+	if out, err := runCommand(t, req.Command, "-s", url, "-trace-file", reqSpans, "untraced.nobody", "hello"); err == nil {
+		t.Fatalf("request without responders succeeded:\n%s", out)
+	}
+	if failed := waitForSpans(t, reqSpans, 2)[1]; failed.Status != "error" || !strings.Contains(failed.Error, "no responders") {
+		t.Fatalf("bad failed request span: %+v", failed)
+	}
+}
+
+func TestTraceEchoReplyHeaders(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+	dir := t.TempDir()
+
+	ec := startCommand(t, echo.Command, "-s", url, "trace.echo")
+	ec.waitFor(t, "Echo Service listening on [trace.echo]")
+
+	// The echo service continues the trace without exporting, and
+	// propagates it in its reply.
+	reqSpans := filepath.Join(dir, "req.jsonl")
+	out := mustRun(t, `"type":"reply"`, req.Command, "-s", url, "-json", "-trace-file", reqSpans, "trace.echo", "hi")
+	root := waitForSpans(t, reqSpans, 1)[0]
+	if !strings.Contains(out, `"traceparent":["00-`+root.TraceID+"-") {
+		t.Fatalf("reply does not continue trace %s:\n%s", root.TraceID, out)
+	}
+}
+
+func TestTraceOTLP(t *testing.T) {
+	s := runServer(t, testServerOptions())
+	url := s.ClientURL()
+
+	var mu sync.Mutex
+	var bodies []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad export", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	mustRun(t, "Published", pub.Command, "-s", url, "-trace-otlp", collector.URL+"/v1/traces", "trace.otlp", "hi")
+
+	// The export completes before the command returns.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("collector received %d exports", len(bodies))
+	}
+	var export struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value struct{ StringValue string }
+				}
+			}
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID, SpanID, Name string
+					Kind                  int
+					Status                struct{ Code int }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &export); err != nil {
+		t.Fatal(err)
+	}
+	rs := export.ResourceSpans[0]
+	span := rs.ScopeSpans[0].Spans[0]
+	if rs.Resource.Attributes[0].Value.StringValue != "nats-pub" || span.Name != "publish" ||
+		span.Kind != 4 || span.Status.Code != 1 || len(span.TraceID) != 32 || len(span.SpanID) != 16 {
+		t.Fatalf("bad export:\n%s", bodies[0])
+	}
+}
+
+// TestTraceOTLPClosed finishes a span after its tracer is closed, as a
+// handler still running at shutdown may.
+func TestTraceOTLPClosed(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer collector.Close()
+
+	tr := trace.New("test", log.New(io.Discard, "", 0))
+	tr.OTLP = collector.URL + "/v1/traces"
+	if err := tr.Open(); err != nil {
+		t.Fatal(err)
+	}
+	span := tr.Start("publish", trace.KindProducer, "trace.closed")
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	span.Finish(nil)
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
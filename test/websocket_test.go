@@ -0,0 +1,109 @@
+// Copyright 2023 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// runWebsocketServer starts a server with a websocket listener, using TLS
+// when cert is given, and returns it with the websocket URL (without path).
+func runWebsocketServer(t *testing.T, cert *tls.Certificate) (*server.Server, string) {
+	t.Helper()
+	opts := testServerOptions()
+	opts.Websocket.Host = "127.0.0.1"
+	opts.Websocket.Port = -1
+	scheme := "ws"
+	if cert != nil {
+		opts.Websocket.TLSConfig = &tls.Config{Certificates: []tls.Certificate{*cert}}
+		scheme = "wss"
+	} else {
+		opts.Websocket.NoTLS = true
+	}
+	s := runServer(t, opts)
+	return s, fmt.Sprintf("%s://127.0.0.1:%d", scheme, opts.Websocket.Port)
+}
+
+// respond answers requests on subj over a plain connection to s.
+func respond(t *testing.T, s *server.Server, subj, reply string) {
+	t.Helper()
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	if _, err := nc.Subscribe(subj, func(m *nats.Msg) { m.Respond([]byte(reply)) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWebsocketPubSubReq(t *testing.T, s *server.Server, url string, tlsArgs ...string) {
+	sub := startTool(t, "nats-sub", append(tlsArgs, "-s", url, "ws.test")...)
+	sub.waitFor(t, "Listening on [ws.test]")
+
+	out, err := runTool(t, "nats-pub", append(tlsArgs, "-s", url, "ws.test", "hello over websocket")...)
+	if err != nil {
+		t.Fatalf("nats-pub: %v\n%s", err, out)
+	}
+	sub.waitFor(t, "Received on [ws.test]")
+	sub.waitFor(t, "Body: 'hello over websocket'")
+
+	respond(t, s, "ws.svc", "pong")
+	out, err = runTool(t, "nats-req", append(tlsArgs, "-s", url, "ws.svc", "ping")...)
+	if err != nil {
+		t.Fatalf("nats-req: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "'pong'") {
+		t.Fatalf("nats-req did not receive reply:\n%s", out)
+	}
+}
+
+func TestWebsocket(t *testing.T) {
+	s, url := runWebsocketServer(t, nil)
+	testWebsocketPubSubReq(t, s, url)
+}
+
+func TestWebsocketProxyPath(t *testing.T) {
+	s, url := runWebsocketServer(t, nil)
+	testWebsocketPubSubReq(t, s, url+"/nats/ws")
+}
+
+func TestWebsocketTLS(t *testing.T) {
+	cert, caFile := writeCert(t, t.TempDir())
+	s, url := runWebsocketServer(t, &cert)
+	testWebsocketPubSubReq(t, s, url, "-tlscacert", caFile)
+
+	// Without the CA the server certificate is not trusted.
+	out, err := runTool(t, "nats-pub", "-s", url, "ws.test", "untrusted")
+	if err == nil {
+		t.Fatalf("nats-pub without CA succeeded:\n%s", out)
+	}
+}
+
+func TestWebsocketMixedURLs(t *testing.T) {
+	_, url := runWebsocketServer(t, nil)
+	out, err := runTool(t, "nats-pub", "-s", url+",nats://127.0.0.1:4222", "ws.test", "mixed")
+	if err == nil || !strings.Contains(out, "only websocket") {
+		t.Fatalf("expected mixed URLs to be rejected, got %v:\n%s", err, out)
+	}
+}